@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchResult holds the outcome of summarizing one batch entry
+type BatchResult struct {
+	Input    string
+	Title    string
+	Summary  string
+	Content  string // Extracted text the summary was made from
+	Err      error
+	Duration time.Duration
+}
+
+// BatchOptions controls a batch run
+type BatchOptions struct {
+	Length       string
+	UseMarkdown  bool
+	EnableSearch bool
+	Workers      int
+	OutputFile   string // Combined markdown report; stdout when empty
+	OutputDir    string // One file per entry instead of a combined report
+}
+
+// ReadBatchInputs reads newline-separated URLs or paths from a file, or from
+// stdin when path is "-". Blank lines and # comments are skipped.
+func ReadBatchInputs(path string) ([]string, error) {
+	var reader io.Reader
+	if path == "-" {
+		reader = os.Stdin
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	var inputs []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		inputs = append(inputs, line)
+	}
+
+	return inputs, scanner.Err()
+}
+
+// RunBatch summarizes all inputs over a bounded worker pool, continuing past
+// failures. Results are returned in input order. Progress is reported as one
+// line per finished entry instead of a spinner per worker.
+func RunBatch(inputs []string, config *Config, opts BatchOptions) []BatchResult {
+	defer SuppressSpinners()()
+
+	results := make([]BatchResult, len(inputs))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	completed := 0
+
+	for w := 0; w < Max(opts.Workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				result := summarizeBatchEntry(inputs[index], config, opts)
+				results[index] = result
+
+				mu.Lock()
+				completed++
+				if result.Err != nil {
+					fmt.Fprintf(os.Stderr, "❌ [%d/%d] %s: %v\n", completed, len(inputs), result.Input, result.Err)
+				} else {
+					fmt.Fprintf(os.Stderr, "✅ [%d/%d] %s (%.1fs)\n", completed, len(inputs), result.Input, result.Duration.Seconds())
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range inputs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// summarizeBatchEntry runs the regular source pipeline for a single entry
+func summarizeBatchEntry(input string, config *Config, opts BatchOptions) BatchResult {
+	start := time.Now()
+	result := BatchResult{Input: input}
+
+	source := DetectSource(input, config)
+	if source == nil || source.Location() == "-" {
+		result.Err = fmt.Errorf("not a URL or file")
+		return result
+	}
+
+	// Batch results are not tied to an interactive session, so cache them directly
+	summary, content, title, err := ProcessSource(source, config, opts.Length, opts.UseMarkdown, opts.EnableSearch, "", nil, nil)
+	result.Summary = summary
+	result.Content = content
+	result.Title = title
+	result.Err = err
+	result.Duration = time.Since(start)
+	return result
+}
+
+// WriteBatchReport writes the results as a combined report or a directory of
+// per-entry files, and returns the number of failures
+func WriteBatchReport(results []BatchResult, opts BatchOptions) (int, error) {
+	failures := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failures++
+		}
+	}
+
+	if opts.OutputDir != "" {
+		return failures, writeBatchDirectory(results, opts.OutputDir)
+	}
+
+	report := buildBatchReport(results, failures)
+	if opts.OutputFile == "" {
+		fmt.Print(report)
+		return failures, nil
+	}
+
+	if err := SaveToFile(opts.OutputFile, report); err != nil {
+		return failures, err
+	}
+	fmt.Fprintf(os.Stderr, "📄 Batch report saved to %s\n", opts.OutputFile)
+	return failures, nil
+}
+
+// buildBatchReport renders all results into one markdown document
+func buildBatchReport(results []BatchResult, failures int) string {
+	var builder strings.Builder
+	builder.WriteString("# Batch Summary Report\n\n")
+	builder.WriteString(fmt.Sprintf("Generated %s: %d sources, %d succeeded, %d failed\n\n",
+		time.Now().Format("2006-01-02 15:04"), len(results), len(results)-failures, failures))
+
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("## %s\n\nSource: %s\n\n%s\n\n---\n\n", batchTitle(result), result.Input, strings.TrimSpace(result.Summary)))
+	}
+
+	builder.WriteString(buildFailureSummary(results))
+	return builder.String()
+}
+
+// writeBatchDirectory writes one markdown file per successful entry plus an index
+func writeBatchDirectory(results []BatchResult, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var index strings.Builder
+	index.WriteString("# Batch Summary Index\n\n")
+
+	for i, result := range results {
+		if result.Err != nil {
+			continue
+		}
+
+		name := cleanSessionName(batchTitle(result))
+		if len(name) > 60 {
+			name = name[:60]
+		}
+		fileName := fmt.Sprintf("%03d_%s.md", i+1, name)
+
+		content := fmt.Sprintf("# %s\n\nSource: %s\n\n%s\n", batchTitle(result), result.Input, strings.TrimSpace(result.Summary))
+		if err := SaveToFile(filepath.Join(dir, fileName), content); err != nil {
+			return err
+		}
+		index.WriteString(fmt.Sprintf("- [%s](%s) - %s\n", batchTitle(result), fileName, result.Input))
+	}
+
+	index.WriteString("\n")
+	index.WriteString(buildFailureSummary(results))
+
+	if err := SaveToFile(filepath.Join(dir, "index.md"), index.String()); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "📁 Batch results saved to %s\n", dir)
+	return nil
+}
+
+// buildFailureSummary lists the entries that could not be summarized
+func buildFailureSummary(results []BatchResult) string {
+	var builder strings.Builder
+	for _, result := range results {
+		if result.Err != nil {
+			builder.WriteString(fmt.Sprintf("- %s: %v\n", result.Input, result.Err))
+		}
+	}
+
+	if builder.Len() == 0 {
+		return ""
+	}
+	return "## Failures\n\n" + builder.String()
+}
+
+// batchTitle returns a display title, falling back to the input itself
+func batchTitle(result BatchResult) string {
+	if result.Title == "" {
+		return result.Input
+	}
+	return result.Title
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
-	"crypto/md5"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,51 +20,195 @@ type CacheEntry struct {
 	Pending   bool        `json:"pending"`
 }
 
+// CacheStore persists cache entries. Expiry and the enabled switch are
+// handled by CacheManager; stores only deal with storage, eviction and the
+// pending/committed state of session entries.
+type CacheStore interface {
+	// Get returns an entry and marks it as recently used
+	Get(key string) (*CacheEntry, bool)
+	// Peek returns an entry without marking it as used, for inspection
+	Peek(key string) (*CacheEntry, bool)
+	// Put stores an entry, evicting least recently used entries over the size limit
+	Put(key string, entry *CacheEntry) error
+	// Delete removes an entry, failing with os.ErrNotExist when there is none
+	Delete(key string) error
+	// CommitSession atomically turns a session's pending entries into regular ones
+	CommitSession(sessionID string) (int, error)
+	// ClearSession removes all entries of a session
+	ClearSession(sessionID string) (int, error)
+	// DeleteExpired removes entries past their TTL and pending entries older than pendingMaxAge
+	DeleteExpired(pendingMaxAge time.Duration) (int, error)
+	Clear() error
+	// List returns the metadata of all entries, without their data
+	List() ([]CacheRecord, error)
+	// AddCounters adds hit and miss counts for a kind of entry
+	AddCounters(kind string, hits, misses int) error
+	// Counters returns the hit and miss counts by kind
+	Counters() (map[string]CacheCounters, error)
+}
+
+// CacheRecord describes a stored entry for inspection
+type CacheRecord struct {
+	Key        string
+	Kind       string
+	Size       int64
+	Timestamp  time.Time
+	AccessedAt time.Time
+	TTL        int
+	SessionID  string
+	Pending    bool
+}
+
+// CacheCounters counts lookups of one kind of entry
+type CacheCounters struct {
+	Hits   int `json:"hits"`
+	Misses int `json:"misses"`
+}
+
+// expired reports whether an entry is past its TTL
+func (e *CacheEntry) expired() bool {
+	return time.Since(e.Timestamp).Hours() > float64(e.TTL)
+}
+
+// cacheStores shares one store per backend and location across the process,
+// since cache managers are created for every operation
+var cacheStores = struct {
+	sync.Mutex
+	stores map[string]CacheStore
+}{stores: make(map[string]CacheStore)}
+
+// openCacheStore returns the configured store, falling back to the file
+// store when SQLite cannot be opened
+func openCacheStore(config *Config) CacheStore {
+	configDir, _ := os.UserConfigDir()
+	cacheDir := filepath.Join(configDir, appName, "cache")
+	maxBytes := int64(config.CacheMaxSizeMB) * 1024 * 1024
+
+	backend := strings.ToLower(config.CacheBackend)
+	storeKey := backend + ":" + cacheDir
+
+	cacheStores.Lock()
+	defer cacheStores.Unlock()
+
+	if store, ok := cacheStores.stores[storeKey]; ok {
+		return store
+	}
+
+	var store CacheStore
+	switch backend {
+	case "sqlite":
+		sqliteStore, err := NewSQLiteCacheStore(filepath.Join(cacheDir, "cache.db"), maxBytes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Could not open SQLite cache, using files: %v\n", err)
+			store = NewFileCacheStore(cacheDir, maxBytes)
+		} else {
+			store = sqliteStore
+		}
+	default:
+		store = NewFileCacheStore(cacheDir, maxBytes)
+	}
+
+	cacheStores.stores[storeKey] = store
+	return store
+}
+
 // CacheManager handles caching operations
 type CacheManager struct {
-	cacheDir string
-	config   *Config
+	store  CacheStore
+	config *Config
 }
 
 // NewCacheManager creates a new cache manager
 func NewCacheManager(config *Config) *CacheManager {
-	configDir, _ := os.UserConfigDir()
-	cacheDir := filepath.Join(configDir, appName, "cache")
-	os.MkdirAll(cacheDir, 0755)
-
 	return &CacheManager{
-		cacheDir: cacheDir,
-		config:   config,
+		store:  openCacheStore(config),
+		config: config,
 	}
 }
 
-// GetCacheKey generates a cache key from input data
-func (cm *CacheManager) GetCacheKey(data string) string {
-	hash := md5.Sum([]byte(data))
-	return fmt.Sprintf("%x", hash)
+// cacheKeyVersion namespaces cache keys. Bump it whenever the key inputs
+// change so older entries are no longer looked up; "cache prune" removes them.
+const cacheKeyVersion = "v2"
+
+// GetCacheKey derives a content-addressed key from the kind and every input
+// that affects the cached result. Parts are JSON-encoded and hashed with
+// SHA-256, so inputs of any length are hashed in full. The kind stays
+// readable in front of the hash, e.g. "url_v2_3f1a...".
+func (cm *CacheManager) GetCacheKey(kind string, parts ...interface{}) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s", cacheKeyVersion, kind)
+	for _, part := range parts {
+		data, err := json.Marshal(part)
+		if err != nil {
+			data = []byte(fmt.Sprint(part))
+		}
+		// Length-prefix each part so ("ab", "c") and ("a", "bc") differ
+		fmt.Fprintf(hash, "\x00%d:", len(data))
+		hash.Write(data)
+	}
+	return fmt.Sprintf("%s_%s_%x", kind, cacheKeyVersion, hash.Sum(nil))
 }
 
-// Get retrieves cached data if valid
-func (cm *CacheManager) Get(key string, target interface{}) bool {
-	if !cm.config.CacheEnabled {
-		return false
+// PromptCacheKey keys the result of a single model call by the model, its
+// options and the exact prompts sent, so changing any of them misses the cache
+func (cm *CacheManager) PromptCacheKey(kind string, task Task, systemPrompt, userPrompt string) string {
+	model, options := cm.config.ModelFor(task)
+	return cm.GetCacheKey(kind, model, options, systemPrompt, userPrompt)
+}
+
+// pipelineFingerprint covers the settings that shape a multi-step summary:
+// every prompt, the model and options of each task and the search setup
+func pipelineFingerprint(config *Config) interface{} {
+	type taskModel struct {
+		Model   string                 `json:"model"`
+		Options map[string]interface{} `json:"options"`
+	}
+	models := make(map[Task]taskModel)
+	for _, task := range []Task{TaskSummary, TaskLength, TaskSearchQuery, TaskOutline} {
+		model, options := config.ModelFor(task)
+		models[task] = taskModel{Model: model, Options: options}
 	}
 
-	filePath := filepath.Join(cm.cacheDir, key+".json")
+	return struct {
+		Prompts SystemPrompts      `json:"prompts"`
+		Models  map[Task]taskModel `json:"models"`
+		Search  SearchConfig       `json:"search"`
+	}{config.SystemPrompts, models, config.Search}
+}
 
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return false
+// CacheKind returns the kind prefix of a cache key, or "legacy" for keys
+// created before kinds were recorded
+func CacheKind(key string) string {
+	if kind, _, found := strings.Cut(key, "_"); found {
+		return kind
 	}
+	return "legacy"
+}
 
-	var entry CacheEntry
-	if err := json.Unmarshal(data, &entry); err != nil {
+// IsCurrentCacheKey reports whether a key belongs to the current key
+// namespace; entries from older versions can never be hit again
+func IsCurrentCacheKey(key string) bool {
+	_, rest, found := strings.Cut(key, "_")
+	return found && strings.HasPrefix(rest, cacheKeyVersion+"_")
+}
+
+// Get retrieves cached data if valid
+func (cm *CacheManager) Get(key string, target interface{}) bool {
+	if !cm.config.CacheEnabled {
 		return false
 	}
 
-	// Check if cache is expired
-	if time.Since(entry.Timestamp).Hours() > float64(entry.TTL) {
-		os.Remove(filePath) // Clean up expired cache
+	// Offline, a stale answer beats none, so expired entries are still served
+	entry, ok := cm.store.Get(key)
+	if ok && entry.expired() && !cm.config.Offline {
+		cm.store.Delete(key) // Clean up expired cache
+		ok = false
+	}
+
+	if ok {
+		cm.store.AddCounters(CacheKind(key), 1, 0)
+	} else {
+		cm.store.AddCounters(CacheKind(key), 0, 1)
 		return false
 	}
 
@@ -81,21 +227,13 @@ func (cm *CacheManager) Set(key string, data interface{}, sessionID string) erro
 		return nil
 	}
 
-	entry := CacheEntry{
+	return cm.store.Put(key, &CacheEntry{
 		Data:      data,
 		Timestamp: time.Now(),
 		TTL:       cm.config.CacheTTL,
 		SessionID: sessionID,
 		Pending:   sessionID != "", // Mark as pending if associated with a session
-	}
-
-	entryBytes, err := json.Marshal(entry)
-	if err != nil {
-		return err
-	}
-
-	filePath := filepath.Join(cm.cacheDir, key+".json")
-	return os.WriteFile(filePath, entryBytes, 0644)
+	})
 }
 
 // CleanExpired removes expired cache entries
@@ -104,51 +242,45 @@ func (cm *CacheManager) CleanExpired() error {
 		return nil
 	}
 
-	entries, err := os.ReadDir(cm.cacheDir)
+	// Also clean pending entries older than 1 hour
+	cleaned, err := cm.store.DeleteExpired(time.Hour)
 	if err != nil {
 		return err
 	}
 
-	cleaned := 0
-	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".json" {
-			filePath := filepath.Join(cm.cacheDir, entry.Name())
-
-			data, err := os.ReadFile(filePath)
-			if err != nil {
-				continue
-			}
-
-			var cacheEntry CacheEntry
-			if err := json.Unmarshal(data, &cacheEntry); err != nil {
-				continue
-			}
-
-			if time.Since(cacheEntry.Timestamp).Hours() > float64(cacheEntry.TTL) || (cacheEntry.Pending && time.Since(cacheEntry.Timestamp).Hours() > 1) { // Also clean pending entries older than 1 hour
-				os.Remove(filePath)
-				cleaned++
-			}
-		}
+	outdated, err := deleteOutdatedKeys(cm.store)
+	if err != nil {
+		return err
 	}
 
-	DebugLog(cm.config, "Cleaned %d expired cache entries", cleaned)
+	DebugLog(cm.config, "Cleaned %d expired and %d outdated cache entries", cleaned, outdated)
 	return nil
 }
 
-// Clear removes all cache entries
-func (cm *CacheManager) Clear() error {
-	entries, err := os.ReadDir(cm.cacheDir)
+// deleteOutdatedKeys removes entries stored under an older key namespace
+func deleteOutdatedKeys(store CacheStore) (int, error) {
+	records, err := store.List()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			os.Remove(filepath.Join(cm.cacheDir, entry.Name()))
+	removed := 0
+	for _, record := range records {
+		if !IsCurrentCacheKey(record.Key) && store.Delete(record.Key) == nil {
+			removed++
 		}
 	}
+	return removed, nil
+}
 
-	return nil
+// Delete removes a single entry
+func (cm *CacheManager) Delete(key string) error {
+	return cm.store.Delete(key)
+}
+
+// Clear removes all cache entries
+func (cm *CacheManager) Clear() error {
+	return cm.store.Clear()
 }
 
 // CommitSessionCache finalizes all pending cache entries for a session
@@ -157,33 +289,11 @@ func (cm *CacheManager) CommitSessionCache(sessionID string) error {
 		return nil
 	}
 
-	entries, err := os.ReadDir(cm.cacheDir)
+	committed, err := cm.store.CommitSession(sessionID)
 	if err != nil {
 		return err
 	}
 
-	committed := 0
-	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".json" {
-			filePath := filepath.Join(cm.cacheDir, entry.Name())
-			data, err := os.ReadFile(filePath)
-			if err != nil {
-				continue
-			}
-
-			var cacheEntry CacheEntry
-			if json.Unmarshal(data, &cacheEntry) == nil && cacheEntry.SessionID == sessionID && cacheEntry.Pending {
-				cacheEntry.Pending = false // No longer pending
-				cacheEntry.SessionID = ""  // Disassociate from session for generic use
-				updatedData, err := json.Marshal(cacheEntry)
-				if err == nil {
-					os.WriteFile(filePath, updatedData, 0644)
-					committed++
-				}
-			}
-		}
-	}
-
 	DebugLog(cm.config, "Committed %d cache entries for session %s", committed, sessionID)
 	return nil
 }
@@ -194,28 +304,11 @@ func (cm *CacheManager) ClearSessionCache(sessionID string) error {
 		return nil
 	}
 
-	entries, err := os.ReadDir(cm.cacheDir)
+	removed, err := cm.store.ClearSession(sessionID)
 	if err != nil {
 		return err
 	}
 
-	removed := 0
-	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".json" {
-			filePath := filepath.Join(cm.cacheDir, entry.Name())
-			data, err := os.ReadFile(filePath)
-			if err != nil {
-				continue
-			}
-
-			var cacheEntry CacheEntry
-			if json.Unmarshal(data, &cacheEntry) == nil && cacheEntry.SessionID == sessionID {
-				os.Remove(filePath)
-				removed++
-			}
-		}
-	}
-
 	DebugLog(cm.config, "Cleared %d cache entries for session %s", removed, sessionID)
 	return nil
 }
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheCommandOptions holds the flags of the cache subcommands
+type CacheCommandOptions struct {
+	Kind      string // Filter for ls
+	OlderThan string // Age for rm, e.g. "7d" or "12h"
+}
+
+// runCacheCommand handles "hvsum cache <stats|ls|show|rm|prune>" and returns the exit code
+func runCacheCommand(args []string, config *Config, opts CacheCommandOptions) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s cache <stats|ls|show|rm|prune> [arguments]\n", appName)
+		return 1
+	}
+
+	store := NewCacheManager(config).store
+	action, rest := args[0], args[1:]
+
+	var err error
+	switch action {
+	case "stats":
+		err = printCacheStats(store)
+	case "ls", "list":
+		err = listCacheEntries(store, opts.Kind)
+	case "show":
+		if len(rest) != 1 {
+			fmt.Fprintf(os.Stderr, "Usage: %s cache show <key>\n", appName)
+			return 1
+		}
+		err = showCacheEntry(store, rest[0])
+	case "rm", "remove":
+		err = removeCacheEntries(store, rest, opts.OlderThan)
+	case "prune":
+		var pruned, outdated int
+		pruned, err = store.DeleteExpired(time.Hour)
+		if err == nil {
+			outdated, err = deleteOutdatedKeys(store)
+		}
+		if err == nil {
+			fmt.Printf("✅ Pruned %d expired or abandoned entries and %d from older cache versions\n", pruned, outdated)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown cache command '%s' (use stats, ls, show, rm or prune)\n", action)
+		return 1
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func printCacheStats(store CacheStore) error {
+	records, err := store.List()
+	if err != nil {
+		return err
+	}
+	counters, err := store.Counters()
+	if err != nil {
+		return err
+	}
+
+	type kindStats struct {
+		entries int
+		size    int64
+	}
+	byKind := make(map[string]*kindStats)
+	var totalSize int64
+	var oldest, newest *CacheRecord
+	for i := range records {
+		record := &records[i]
+		stats, ok := byKind[record.Kind]
+		if !ok {
+			stats = &kindStats{}
+			byKind[record.Kind] = stats
+		}
+		stats.entries++
+		stats.size += record.Size
+		totalSize += record.Size
+
+		if oldest == nil || record.Timestamp.Before(oldest.Timestamp) {
+			oldest = record
+		}
+		if newest == nil || record.Timestamp.After(newest.Timestamp) {
+			newest = record
+		}
+	}
+
+	kinds := make(map[string]bool)
+	for kind := range byKind {
+		kinds[kind] = true
+	}
+	for kind := range counters {
+		kinds[kind] = true
+	}
+	var sortedKinds []string
+	for kind := range kinds {
+		sortedKinds = append(sortedKinds, kind)
+	}
+	sort.Strings(sortedKinds)
+
+	fmt.Printf("📦 Cache: %d entries, %s\n\n", len(records), formatBytes(totalSize))
+	fmt.Printf("  %-10s %8s %10s %8s %8s %8s\n", "KIND", "ENTRIES", "SIZE", "HITS", "MISSES", "HIT %")
+
+	var totalHits, totalMisses int
+	for _, kind := range sortedKinds {
+		stats := byKind[kind]
+		if stats == nil {
+			stats = &kindStats{}
+		}
+		counter := counters[kind]
+		totalHits += counter.Hits
+		totalMisses += counter.Misses
+		fmt.Printf("  %-10s %8d %10s %8d %8d %8s\n", kind, stats.entries, formatBytes(stats.size), counter.Hits, counter.Misses, hitRate(counter.Hits, counter.Misses))
+	}
+	fmt.Printf("  %-10s %8d %10s %8d %8d %8s\n", "total", len(records), formatBytes(totalSize), totalHits, totalMisses, hitRate(totalHits, totalMisses))
+
+	if oldest != nil {
+		fmt.Printf("\nOldest: %s (%s)\n", oldest.Key, oldest.Timestamp.Format("2006-01-02 15:04"))
+		fmt.Printf("Newest: %s (%s)\n", newest.Key, newest.Timestamp.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+func listCacheEntries(store CacheStore, kind string) error {
+	records, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Timestamp.After(records[j].Timestamp)
+	})
+
+	shown := 0
+	for _, record := range records {
+		if kind != "" && record.Kind != kind {
+			continue
+		}
+		state := ""
+		if record.Pending {
+			state = " pending:" + record.SessionID
+		}
+		fmt.Printf("%s  %s  %8s%s\n", record.Key, record.Timestamp.Format("2006-01-02 15:04"), formatBytes(record.Size), state)
+		shown++
+	}
+
+	if shown == 0 {
+		fmt.Println("No cache entries found.")
+	}
+	return nil
+}
+
+func showCacheEntry(store CacheStore, key string) error {
+	entry, ok := store.Peek(key)
+	if !ok {
+		return fmt.Errorf("no cache entry %s", key)
+	}
+
+	fmt.Printf("Key: %s\n", key)
+	fmt.Printf("Kind: %s\n", CacheKind(key))
+	fmt.Printf("Created: %s\n", entry.Timestamp.Format("2006-01-02 15:04:05"))
+	fmt.Printf("TTL: %d hours (expired: %t)\n", entry.TTL, entry.expired())
+	if entry.SessionID != "" {
+		fmt.Printf("Session: %s (pending: %t)\n", entry.SessionID, entry.Pending)
+	}
+
+	// Strings are shown as-is, everything else as indented JSON
+	var text string
+	data, _ := json.Marshal(entry.Data)
+	if json.Unmarshal(data, &text) != nil {
+		indented, err := json.MarshalIndent(entry.Data, "", "  ")
+		if err != nil {
+			return err
+		}
+		text = string(indented)
+	}
+	fmt.Printf("\n%s\n", text)
+	return nil
+}
+
+func removeCacheEntries(store CacheStore, keys []string, olderThan string) error {
+	if olderThan == "" && len(keys) == 0 {
+		return fmt.Errorf("give keys to remove or --older-than, e.g. --older-than 7d")
+	}
+
+	removed := 0
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil {
+			return fmt.Errorf("could not remove %s: %v", key, err)
+		}
+		removed++
+	}
+
+	if olderThan != "" {
+		age, err := parseAge(olderThan)
+		if err != nil {
+			return err
+		}
+		records, err := store.List()
+		if err != nil {
+			return err
+		}
+		cutoff := time.Now().Add(-age)
+		for _, record := range records {
+			if record.Timestamp.Before(cutoff) && store.Delete(record.Key) == nil {
+				removed++
+			}
+		}
+	}
+
+	fmt.Printf("✅ Removed %d cache entries\n", removed)
+	return nil
+}
+
+// parseAge accepts Go durations plus a "d" suffix for days, e.g. "7d" or "36h"
+func parseAge(value string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(value, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age '%s'", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	age, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age '%s' (use e.g. 7d or 12h)", value)
+	}
+	return age, nil
+}
+
+func hitRate(hits, misses int) string {
+	if hits+misses == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", float64(hits)*100/float64(hits+misses))
+}
+
+func formatBytes(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileCacheStore keeps one JSON file per key in a flat directory. The file
+// modification time doubles as the last access time for LRU eviction.
+type FileCacheStore struct {
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex // Serializes counter updates within this process
+}
+
+// NewFileCacheStore creates a file store; maxBytes <= 0 disables the size limit
+func NewFileCacheStore(dir string, maxBytes int64) *FileCacheStore {
+	os.MkdirAll(dir, 0755)
+	return &FileCacheStore{dir: dir, maxBytes: maxBytes}
+}
+
+func (fs *FileCacheStore) path(key string) string {
+	return filepath.Join(fs.dir, key+".json")
+}
+
+func (fs *FileCacheStore) Get(key string) (*CacheEntry, bool) {
+	entry, ok := fs.Peek(key)
+	if !ok {
+		return nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(fs.path(key), now, now) // Mark as recently used
+	return entry, true
+}
+
+func (fs *FileCacheStore) Peek(key string) (*CacheEntry, bool) {
+	entry, err := readCacheFile(fs.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (fs *FileCacheStore) Put(key string, entry *CacheEntry) error {
+	if err := fs.write(fs.path(key), entry); err != nil {
+		return err
+	}
+	fs.evict()
+	return nil
+}
+
+func (fs *FileCacheStore) Delete(key string) error {
+	return os.Remove(fs.path(key))
+}
+
+func (fs *FileCacheStore) CommitSession(sessionID string) (int, error) {
+	committed := 0
+	err := fs.each(func(path string, entry *CacheEntry) {
+		if entry.SessionID == sessionID && entry.Pending {
+			entry.Pending = false // No longer pending
+			entry.SessionID = ""  // Disassociate from session for generic use
+			if fs.write(path, entry) == nil {
+				committed++
+			}
+		}
+	})
+	return committed, err
+}
+
+func (fs *FileCacheStore) ClearSession(sessionID string) (int, error) {
+	removed := 0
+	err := fs.each(func(path string, entry *CacheEntry) {
+		if entry.SessionID == sessionID && os.Remove(path) == nil {
+			removed++
+		}
+	})
+	return removed, err
+}
+
+func (fs *FileCacheStore) DeleteExpired(pendingMaxAge time.Duration) (int, error) {
+	cleaned := 0
+	err := fs.each(func(path string, entry *CacheEntry) {
+		if entry.expired() || (entry.Pending && time.Since(entry.Timestamp) > pendingMaxAge) {
+			if os.Remove(path) == nil {
+				cleaned++
+			}
+		}
+	})
+	return cleaned, err
+}
+
+func (fs *FileCacheStore) Clear() error {
+	files, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if !file.IsDir() {
+			os.Remove(filepath.Join(fs.dir, file.Name()))
+		}
+	}
+	return nil
+}
+
+func (fs *FileCacheStore) List() ([]CacheRecord, error) {
+	files, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []CacheRecord
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		entry, err := readCacheFile(filepath.Join(fs.dir, file.Name()))
+		if err != nil {
+			continue
+		}
+
+		key := strings.TrimSuffix(file.Name(), ".json")
+		records = append(records, CacheRecord{
+			Key:        key,
+			Kind:       CacheKind(key),
+			Size:       info.Size(),
+			Timestamp:  entry.Timestamp,
+			AccessedAt: info.ModTime(),
+			TTL:        entry.TTL,
+			SessionID:  entry.SessionID,
+			Pending:    entry.Pending,
+		})
+	}
+	return records, nil
+}
+
+// countersPath has no .json extension so it is never taken for an entry
+func (fs *FileCacheStore) countersPath() string {
+	return filepath.Join(fs.dir, "counters")
+}
+
+func (fs *FileCacheStore) AddCounters(kind string, hits, misses int) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	counters, _ := fs.Counters()
+	if counters == nil {
+		counters = make(map[string]CacheCounters)
+	}
+	current := counters[kind]
+	current.Hits += hits
+	current.Misses += misses
+	counters[kind] = current
+
+	data, err := json.Marshal(counters)
+	if err != nil {
+		return err
+	}
+	// Other hvsum processes may update the counters at the same time; the
+	// rename keeps them from ever reading a half-written file
+	return fs.writeFile(fs.countersPath(), data)
+}
+
+func (fs *FileCacheStore) Counters() (map[string]CacheCounters, error) {
+	data, err := os.ReadFile(fs.countersPath())
+	if os.IsNotExist(err) {
+		return map[string]CacheCounters{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var counters map[string]CacheCounters
+	if err := json.Unmarshal(data, &counters); err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
+
+// write replaces a cache file atomically through a temporary file
+func (fs *FileCacheStore) write(path string, entry *CacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return fs.writeFile(path, data)
+}
+
+// writeFile writes data to a temporary file in the cache dir and renames it
+// over path
+func (fs *FileCacheStore) writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(fs.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// each calls fn for every readable cache file
+func (fs *FileCacheStore) each(fn func(path string, entry *CacheEntry)) error {
+	files, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(fs.dir, file.Name())
+		if entry, err := readCacheFile(path); err == nil {
+			fn(path, entry)
+		}
+	}
+	return nil
+}
+
+// evict removes the least recently used files until the cache fits in maxBytes
+func (fs *FileCacheStore) evict() {
+	if fs.maxBytes <= 0 {
+		return
+	}
+
+	files, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return
+	}
+
+	var infos []os.FileInfo
+	var total int64
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		if info, err := file.Info(); err == nil {
+			infos = append(infos, info)
+			total += info.Size()
+		}
+	}
+	if total <= fs.maxBytes {
+		return
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		if total <= fs.maxBytes {
+			break
+		}
+		if os.Remove(filepath.Join(fs.dir, info.Name())) == nil {
+			total -= info.Size()
+		}
+	}
+}
+
+func readCacheFile(path string) (*CacheEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure-Go driver, registered as "sqlite"
+)
+
+const sqliteCacheSchema = `
+CREATE TABLE IF NOT EXISTS cache (
+	key         TEXT PRIMARY KEY,
+	data        BLOB NOT NULL,
+	timestamp   INTEGER NOT NULL,
+	ttl_hours   INTEGER NOT NULL,
+	session_id  TEXT NOT NULL DEFAULT '',
+	pending     INTEGER NOT NULL DEFAULT 0,
+	size        INTEGER NOT NULL,
+	accessed_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS cache_session ON cache (session_id, pending);
+CREATE INDEX IF NOT EXISTS cache_pending ON cache (pending, timestamp);
+CREATE INDEX IF NOT EXISTS cache_accessed ON cache (accessed_at);
+CREATE TABLE IF NOT EXISTS cache_counters (
+	kind   TEXT PRIMARY KEY,
+	hits   INTEGER NOT NULL DEFAULT 0,
+	misses INTEGER NOT NULL DEFAULT 0
+);
+`
+
+// SQLiteCacheStore keeps the cache in a single SQLite database, so session
+// commits are one transaction and lookups by session don't scan every entry
+type SQLiteCacheStore struct {
+	db       *sql.DB
+	maxBytes int64
+}
+
+// NewSQLiteCacheStore opens or creates the database; maxBytes <= 0 disables the size limit
+func NewSQLiteCacheStore(path string, maxBytes int64) (*SQLiteCacheStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteCacheSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create cache schema: %w", err)
+	}
+
+	return &SQLiteCacheStore{db: db, maxBytes: maxBytes}, nil
+}
+
+func (ss *SQLiteCacheStore) Get(key string) (*CacheEntry, bool) {
+	entry, ok := ss.Peek(key)
+	if !ok {
+		return nil, false
+	}
+
+	ss.db.Exec(`UPDATE cache SET accessed_at = ? WHERE key = ?`, time.Now().UnixNano(), key)
+	return entry, true
+}
+
+func (ss *SQLiteCacheStore) Peek(key string) (*CacheEntry, bool) {
+	var data []byte
+	var timestamp int64
+	var pending int
+	entry := &CacheEntry{}
+
+	err := ss.db.QueryRow(`SELECT data, timestamp, ttl_hours, session_id, pending FROM cache WHERE key = ?`, key).
+		Scan(&data, &timestamp, &entry.TTL, &entry.SessionID, &pending)
+	if err != nil {
+		return nil, false
+	}
+
+	entry.Data = json.RawMessage(data)
+	entry.Timestamp = time.Unix(0, timestamp)
+	entry.Pending = pending != 0
+	return entry, true
+}
+
+func (ss *SQLiteCacheStore) Put(key string, entry *CacheEntry) error {
+	data, err := json.Marshal(entry.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = ss.db.Exec(`INSERT OR REPLACE INTO cache (key, data, timestamp, ttl_hours, session_id, pending, size, accessed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		key, data, entry.Timestamp.UnixNano(), entry.TTL, entry.SessionID, entry.Pending, len(data), time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+
+	return ss.evict()
+}
+
+func (ss *SQLiteCacheStore) Delete(key string) error {
+	result, err := ss.db.Exec(`DELETE FROM cache WHERE key = ?`, key)
+	if err != nil {
+		return err
+	}
+
+	if removed, _ := result.RowsAffected(); removed == 0 {
+		return fmt.Errorf("no cache entry %s: %w", key, os.ErrNotExist)
+	}
+	return nil
+}
+
+func (ss *SQLiteCacheStore) CommitSession(sessionID string) (int, error) {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE cache SET pending = 0, session_id = '' WHERE session_id = ? AND pending = 1`, sessionID)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	committed, _ := result.RowsAffected()
+	return int(committed), nil
+}
+
+func (ss *SQLiteCacheStore) ClearSession(sessionID string) (int, error) {
+	result, err := ss.db.Exec(`DELETE FROM cache WHERE session_id = ?`, sessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	removed, _ := result.RowsAffected()
+	return int(removed), nil
+}
+
+func (ss *SQLiteCacheStore) DeleteExpired(pendingMaxAge time.Duration) (int, error) {
+	now := time.Now().UnixNano()
+	result, err := ss.db.Exec(`DELETE FROM cache
+		WHERE timestamp + ttl_hours * ? < ?
+		   OR (pending = 1 AND timestamp < ?)`,
+		int64(time.Hour), now, now-int64(pendingMaxAge))
+	if err != nil {
+		return 0, err
+	}
+
+	cleaned, _ := result.RowsAffected()
+	return int(cleaned), nil
+}
+
+func (ss *SQLiteCacheStore) Clear() error {
+	_, err := ss.db.Exec(`DELETE FROM cache; DELETE FROM cache_counters`)
+	return err
+}
+
+func (ss *SQLiteCacheStore) List() ([]CacheRecord, error) {
+	rows, err := ss.db.Query(`SELECT key, size, timestamp, accessed_at, ttl_hours, session_id, pending FROM cache`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []CacheRecord
+	for rows.Next() {
+		var record CacheRecord
+		var timestamp, accessedAt int64
+		var pending int
+		if err := rows.Scan(&record.Key, &record.Size, &timestamp, &accessedAt, &record.TTL, &record.SessionID, &pending); err != nil {
+			return nil, err
+		}
+		record.Kind = CacheKind(record.Key)
+		record.Timestamp = time.Unix(0, timestamp)
+		record.AccessedAt = time.Unix(0, accessedAt)
+		record.Pending = pending != 0
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (ss *SQLiteCacheStore) AddCounters(kind string, hits, misses int) error {
+	_, err := ss.db.Exec(`INSERT INTO cache_counters (kind, hits, misses) VALUES (?, ?, ?)
+		ON CONFLICT (kind) DO UPDATE SET hits = hits + excluded.hits, misses = misses + excluded.misses`,
+		kind, hits, misses)
+	return err
+}
+
+func (ss *SQLiteCacheStore) Counters() (map[string]CacheCounters, error) {
+	rows, err := ss.db.Query(`SELECT kind, hits, misses FROM cache_counters`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counters := make(map[string]CacheCounters)
+	for rows.Next() {
+		var kind string
+		var counter CacheCounters
+		if err := rows.Scan(&kind, &counter.Hits, &counter.Misses); err != nil {
+			return nil, err
+		}
+		counters[kind] = counter
+	}
+	return counters, rows.Err()
+}
+
+// evict deletes the least recently used entries beyond maxBytes
+func (ss *SQLiteCacheStore) evict() error {
+	if ss.maxBytes <= 0 {
+		return nil
+	}
+
+	var total int64
+	if err := ss.db.QueryRow(`SELECT COALESCE(SUM(size), 0) FROM cache`).Scan(&total); err != nil || total <= ss.maxBytes {
+		return err
+	}
+
+	// Keep the most recently used entries that fit within the limit
+	_, err := ss.db.Exec(`DELETE FROM cache WHERE key IN (
+		SELECT key FROM (
+			SELECT key, SUM(size) OVER (ORDER BY accessed_at DESC, key) AS running FROM cache
+		) WHERE running > ?
+	)`, ss.maxBytes)
+	return err
+}
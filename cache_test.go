@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheStoreDeleteMissingKey(t *testing.T) {
+	dir := t.TempDir()
+	sqliteStore, err := NewSQLiteCacheStore(filepath.Join(dir, "cache.db"), 0)
+	if err != nil {
+		t.Fatalf("open SQLite store: %v", err)
+	}
+	stores := map[string]CacheStore{
+		"file":   NewFileCacheStore(filepath.Join(dir, "files"), 0),
+		"sqlite": sqliteStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if err := store.Put("url_v2_abc", &CacheEntry{Data: "x", Timestamp: time.Now(), TTL: 1}); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if err := store.Delete("url_v2_abc"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if err := store.Delete("url_v2_abc"); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("second Delete = %v, want os.ErrNotExist", err)
+			}
+		})
+	}
+}
+
+func TestCacheStorePeekKeepsAccessTime(t *testing.T) {
+	dir := t.TempDir()
+	sqliteStore, err := NewSQLiteCacheStore(filepath.Join(dir, "cache.db"), 0)
+	if err != nil {
+		t.Fatalf("open SQLite store: %v", err)
+	}
+	stores := map[string]CacheStore{
+		"file":   NewFileCacheStore(filepath.Join(dir, "files"), 0),
+		"sqlite": sqliteStore,
+	}
+
+	accessedAt := func(store CacheStore) time.Time {
+		records, err := store.List()
+		if err != nil || len(records) != 1 {
+			t.Fatalf("List = %v, %v", records, err)
+		}
+		return records[0].AccessedAt
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			store.Put("url_v2_abc", &CacheEntry{Data: "x", Timestamp: time.Now(), TTL: 1})
+			stored := accessedAt(store)
+			time.Sleep(20 * time.Millisecond)
+
+			if _, ok := store.Peek("url_v2_abc"); !ok {
+				t.Fatal("Peek found no entry")
+			}
+			if got := accessedAt(store); !got.Equal(stored) {
+				t.Errorf("Peek moved the access time from %v to %v", stored, got)
+			}
+
+			store.Get("url_v2_abc")
+			if got := accessedAt(store); !got.After(stored) {
+				t.Errorf("Get kept the access time at %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// charsPerToken is a rough average that holds well enough for English prose
+const charsPerToken = 4
+
+// EstimateTokens approximates the number of tokens in a string
+func EstimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + charsPerToken - 1) / charsPerToken
+}
+
+// ChunkText splits text into pieces of at most maxTokens, keeping paragraphs
+// together where possible and repeating up to overlapTokens of trailing
+// paragraphs at the start of the next chunk.
+func ChunkText(text string, maxTokens, overlapTokens int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if maxTokens <= 0 || EstimateTokens(text) <= maxTokens {
+		return []string{text}
+	}
+	if overlapTokens >= maxTokens {
+		overlapTokens = maxTokens / 4
+	}
+
+	// Break the text into paragraphs no larger than a single chunk
+	var pieces []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		if EstimateTokens(paragraph) <= maxTokens {
+			pieces = append(pieces, paragraph)
+			continue
+		}
+		pieces = append(pieces, splitByWords(paragraph, maxTokens)...)
+	}
+
+	var chunks []string
+	var current []string
+	currentTokens := 0
+
+	for _, piece := range pieces {
+		pieceTokens := EstimateTokens(piece) + 1
+		if currentTokens+pieceTokens > maxTokens && len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, "\n"))
+
+			// Carry trailing paragraphs over as overlap
+			var overlap []string
+			overlapSize := 0
+			for i := len(current) - 1; i >= 0; i-- {
+				size := EstimateTokens(current[i]) + 1
+				if overlapSize+size > overlapTokens || overlapSize+size+pieceTokens > maxTokens {
+					break
+				}
+				overlap = append([]string{current[i]}, overlap...)
+				overlapSize += size
+			}
+			current = overlap
+			currentTokens = overlapSize
+		}
+		current = append(current, piece)
+		currentTokens += pieceTokens
+	}
+
+	if len(current) > 0 {
+		chunks = append(chunks, strings.Join(current, "\n"))
+	}
+
+	return chunks
+}
+
+// splitByWords breaks an oversized paragraph into word-aligned pieces
+func splitByWords(paragraph string, maxTokens int) []string {
+	var pieces []string
+	var builder strings.Builder
+
+	for _, word := range strings.Fields(paragraph) {
+		if builder.Len() > 0 && EstimateTokens(builder.String())+EstimateTokens(word)+1 > maxTokens {
+			pieces = append(pieces, builder.String())
+			builder.Reset()
+		}
+		if builder.Len() > 0 {
+			builder.WriteString(" ")
+		}
+		builder.WriteString(word)
+	}
+
+	if builder.Len() > 0 {
+		pieces = append(pieces, builder.String())
+	}
+	return pieces
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// citationInstruction asks the model to reference search results by number
+const citationInstruction = `CITATIONS: When you use information from the web search results, cite them inline with their bracketed numbers, e.g. [1] or [2][3]. Only cite numbers that exist in the search results. Do not add a sources or references list yourself.`
+
+// citationPattern matches the inside of an inline citation such as [3] or [1, 4]
+var citationPattern = regexp.MustCompile(`^\d+(?:\s*,\s*\d+)*$`)
+
+// maxCitationBytes bounds how much text is held back as a possible citation
+const maxCitationBytes = 32
+
+// ApplyCitations validates inline [n] citations against the search results,
+// dropping references to results that don't exist, and builds a sources list
+// for the cited results. It returns the cleaned text and the sources footer.
+func ApplyCitations(text string, results []SearchResult, useMarkdown bool) (string, string) {
+	if len(results) == 0 {
+		return text, ""
+	}
+
+	filter := newCitationFilter(len(results))
+	body := filter.Write(text) + filter.Flush()
+	return body, FormatSources(results, filter.cited, useMarkdown)
+}
+
+// StripCitations removes inline [n] citations, such as the reference
+// markers of a page that carry over into its summary
+func StripCitations(text string) string {
+	filter := newCitationFilter(0)
+	return filter.Write(text) + filter.Flush()
+}
+
+// citationStream wraps a stream so that citations reach it validated the
+// same way ApplyCitations rewrites the final text. Text that may still turn
+// out to be a citation is held back until the call to the returned flush.
+func citationStream(stream StreamFunc, results []SearchResult) (StreamFunc, func()) {
+	if stream == nil || len(results) == 0 {
+		return stream, func() {}
+	}
+
+	filter := newCitationFilter(len(results))
+	write := func(token string) {
+		if text := filter.Write(token); text != "" {
+			stream(text)
+		}
+	}
+	flush := func() {
+		if text := filter.Flush(); text != "" {
+			stream(text)
+		}
+	}
+	return write, flush
+}
+
+// citationFilter rewrites citations in text that arrives in pieces. Brackets
+// in code spans and fences, and brackets right after an identifier such as
+// arr[0], are not citations. Markdown links like [1](https://...) are kept.
+type citationFilter struct {
+	count int
+	cited map[int]bool
+
+	out     strings.Builder
+	spaces  string          // Spaces held back, dropped with an invalid citation
+	pending strings.Builder // A possible citation, from its "["
+	closed  bool            // pending ends with "]" and waits for the next rune
+	prev    rune
+
+	lineStart   bool // Only indentation so far on this line
+	inFence     bool
+	fenceChar   rune
+	fenceLen    int
+	inlineTicks int  // Length of the backtick run that opened a code span
+	runChar     rune // Current run of backticks or tildes
+	runLen      int
+	runAtStart  bool
+}
+
+func newCitationFilter(count int) *citationFilter {
+	return &citationFilter{count: count, cited: make(map[int]bool), lineStart: true}
+}
+
+// Write consumes the next piece of text and returns what can be emitted
+func (f *citationFilter) Write(text string) string {
+	for _, r := range text {
+		f.consume(r)
+	}
+	return f.take()
+}
+
+// Flush returns everything still held back, at the end of the text
+func (f *citationFilter) Flush() string {
+	if f.pending.Len() > 0 {
+		if f.closed {
+			f.resolveCitation()
+		} else {
+			f.abandonCitation()
+		}
+	}
+	f.emitSpaces()
+	return f.take()
+}
+
+func (f *citationFilter) take() string {
+	text := f.out.String()
+	f.out.Reset()
+	return text
+}
+
+func (f *citationFilter) consume(r rune) {
+	if f.pending.Len() > 0 {
+		if f.consumeCitation(r) {
+			return
+		}
+	}
+
+	if r == '`' || r == '~' {
+		if f.runLen > 0 && f.runChar == r {
+			f.runLen++
+		} else {
+			f.endRun()
+			f.runChar, f.runLen, f.runAtStart = r, 1, f.lineStart
+		}
+		f.emit(r)
+		return
+	}
+	f.endRun()
+
+	switch {
+	case f.inFence || f.inlineTicks > 0:
+		f.emit(r)
+	case r == ' ':
+		f.spaces += " "
+	case r == '[' && !isIdentifierRune(f.prev):
+		f.pending.WriteRune(r)
+	default:
+		f.emit(r)
+	}
+	f.prev = r
+}
+
+// consumeCitation extends a possible citation and reports whether r was used
+func (f *citationFilter) consumeCitation(r rune) bool {
+	if f.closed {
+		if r == '(' {
+			f.abandonCitation()
+		} else {
+			f.resolveCitation()
+		}
+		return false
+	}
+
+	switch {
+	case r == ']':
+		if !citationPattern.MatchString(f.pending.String()[1:]) {
+			f.abandonCitation()
+			return false
+		}
+		f.pending.WriteRune(r)
+		f.closed = true
+		f.prev = r
+		return true
+	case (r >= '0' && r <= '9') || r == ',' || r == ' ' || r == '\t':
+		if f.pending.Len() >= maxCitationBytes {
+			f.abandonCitation()
+			return false
+		}
+		f.pending.WriteRune(r)
+		f.prev = r
+		return true
+	default:
+		f.abandonCitation()
+		return false
+	}
+}
+
+// resolveCitation keeps the valid numbers of a complete citation. Without
+// any, the citation is dropped along with the spaces before it.
+func (f *citationFilter) resolveCitation() {
+	inner := strings.TrimSuffix(strings.TrimPrefix(f.pending.String(), "["), "]")
+	f.pending.Reset()
+	f.closed = false
+
+	var valid []string
+	for _, part := range strings.Split(inner, ",") {
+		index, err := strconv.Atoi(strings.TrimSpace(part))
+		if err == nil && index >= 1 && index <= f.count {
+			f.cited[index] = true
+			valid = append(valid, strconv.Itoa(index))
+		}
+	}
+
+	if len(valid) == 0 {
+		f.spaces = ""
+		return
+	}
+	f.emitSpaces()
+	f.out.WriteString("[" + strings.Join(valid, ", ") + "]")
+	f.lineStart = false
+}
+
+// abandonCitation emits held back text that turned out not to be a citation
+func (f *citationFilter) abandonCitation() {
+	text := f.pending.String()
+	f.pending.Reset()
+	f.closed = false
+
+	f.emitSpaces()
+	f.out.WriteString(text)
+	f.lineStart = false
+}
+
+// endRun interprets a finished run of backticks or tildes: three or more at
+// the start of a line open or close a fence, backticks elsewhere open or
+// close a code span of the same length
+func (f *citationFilter) endRun() {
+	if f.runLen == 0 {
+		return
+	}
+	char, length, atStart := f.runChar, f.runLen, f.runAtStart
+	f.runLen = 0
+
+	switch {
+	case atStart && length >= 3 && !f.inFence && f.inlineTicks == 0:
+		f.inFence, f.fenceChar, f.fenceLen = true, char, length
+	case atStart && f.inFence && char == f.fenceChar && length >= f.fenceLen:
+		f.inFence = false
+	case f.inFence || char != '`':
+	case f.inlineTicks == 0:
+		f.inlineTicks = length
+	case f.inlineTicks == length:
+		f.inlineTicks = 0
+	}
+}
+
+func (f *citationFilter) emit(r rune) {
+	f.emitSpaces()
+	f.out.WriteRune(r)
+	if r == '\n' {
+		// Unclosed code spans end with the line, so a stray backtick can't
+		// disable citations for the rest of the text
+		f.inlineTicks = 0
+		f.lineStart = true
+	} else if r != '\t' {
+		f.lineStart = false
+	}
+	f.prev = r
+}
+
+func (f *citationFilter) emitSpaces() {
+	f.out.WriteString(f.spaces)
+	f.spaces = ""
+}
+
+// isIdentifierRune reports whether r can end an identifier, as in arr[0]
+func isIdentifierRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// FormatSources lists the cited search results, or all of them when none were cited
+func FormatSources(results []SearchResult, cited map[int]bool, useMarkdown bool) string {
+	if len(results) == 0 {
+		return ""
+	}
+
+	var indices []int
+	for index := range cited {
+		indices = append(indices, index)
+	}
+	if len(indices) == 0 {
+		for i := range results {
+			indices = append(indices, i+1)
+		}
+	}
+	sort.Ints(indices)
+
+	var builder strings.Builder
+	if useMarkdown {
+		builder.WriteString("\n\n## Sources\n\n")
+	} else {
+		builder.WriteString("\n\nSources:\n")
+	}
+
+	for _, index := range indices {
+		result := results[index-1]
+		if useMarkdown {
+			builder.WriteString(fmt.Sprintf("- [%d] [%s](%s)\n", index, result.Title, result.URL))
+		} else {
+			builder.WriteString(fmt.Sprintf("- [%d] %s: %s\n", index, result.Title, result.URL))
+		}
+	}
+
+	return strings.TrimRight(builder.String(), "\n")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyCitations(t *testing.T) {
+	results := []SearchResult{
+		{Title: "One", URL: "https://one.example"},
+		{Title: "Two", URL: "https://two.example"},
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"valid", "Fact [1] and more [2].", "Fact [1] and more [2]."},
+		{"invalid dropped with space", "Fact [7]. Other [1, 9].", "Fact. Other [1]."},
+		{"adjacent", "Fact [1][2][5].", "Fact [1][2]."},
+		{"markdown link", "See [3](https://x.example).", "See [3](https://x.example)."},
+		{"index expressions", "Use arr[0], buf[10] and items[2].", "Use arr[0], buf[10] and items[2]."},
+		{"code span", "Call `get [5]` now [5].", "Call `get [5]` now."},
+		{"double backtick span", "Call ``a ` [5]`` now.", "Call ``a ` [5]`` now."},
+		{"fence", "Text:\n```go\nx := m [4]\n```\nDone [4].", "Text:\n```go\nx := m [4]\n```\nDone."},
+		{"tilde fence", "~~~\n[9]\n~~~\n[9] end", "~~~\n[9]\n~~~\n end"},
+		{"unclosed backtick ends with line", "a ` b\nc [9].", "a ` b\nc."},
+		{"not a citation", "A [note] and [1 2] stay.", "A [note] and [1 2] stay."},
+		{"unicode", "Zitat [2] über [8] alles", "Zitat [2] über alles"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := ApplyCitations(tt.text, results, false)
+			if got != tt.want {
+				t.Errorf("ApplyCitations(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+
+			// Streaming token by token yields the same text
+			var streamed strings.Builder
+			stream, flush := citationStream(func(token string) { streamed.WriteString(token) }, results)
+			for _, r := range tt.text {
+				stream(string(r))
+			}
+			flush()
+			if streamed.String() != tt.want {
+				t.Errorf("streamed %q, want %q", streamed.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyCitationsSources(t *testing.T) {
+	results := []SearchResult{
+		{Title: "One", URL: "https://one.example"},
+		{Title: "Two", URL: "https://two.example"},
+	}
+
+	_, sources := ApplyCitations("Only the second [2], not `[1]`.", results, false)
+	if !strings.Contains(sources, "[2] Two") || strings.Contains(sources, "[1] One") {
+		t.Errorf("sources = %q, want only the cited result", sources)
+	}
+
+	// Without citations every result is listed
+	_, sources = ApplyCitations("No citations.", results, true)
+	if !strings.Contains(sources, "- [1] [One](https://one.example)") || !strings.Contains(sources, "- [2] [Two](https://two.example)") {
+		t.Errorf("sources = %q, want all results", sources)
+	}
+
+	if text, sources := ApplyCitations("Fact [1].", nil, false); text != "Fact [1]." || sources != "" {
+		t.Errorf("without results got %q, %q", text, sources)
+	}
+}
+
+func TestStripCitations(t *testing.T) {
+	got := StripCitations("Rust is fast [12] and safe [3, 4]. Use `v[1]` or v[2].")
+	if want := "Rust is fast and safe. Use `v[1]` or v[2]."; got != want {
+		t.Errorf("StripCitations = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sourceAttributionInstruction asks the model to credit facts to the
+// numbered sources of a multi-document session
+const sourceAttributionInstruction = `SOURCES: The content comes from several numbered sources. Attribute every statement to its source with the bracketed number, e.g. [1] or [2, 3], and point out where the sources disagree.`
+
+// SynthesizeSources compares the summaries of several sources, covering
+// agreements, contradictions and the points unique to each source, with
+// inline [n] attributions. Each source gets an equal share of the context.
+func SynthesizeSources(config *Config, documents []SessionDocument, length string, useMarkdown bool, stream StreamFunc) (string, error) {
+	lengthInstruction, exists := lengthMap[length]
+	if !exists {
+		lengthInstruction = lengthMap["medium"]
+	}
+
+	systemPrompt := fmt.Sprintf(`You are an expert analyst comparing several sources on related topics. Write a synthesis of the numbered source summaries you are given.
+
+Cover:
+1. Agreements: points that several sources make
+2. Contradictions: where sources disagree, stating each side
+3. Unique points: what only one source contributes, per source
+
+Rules:
+- Attribute every statement inline with the bracketed source numbers, e.g. [1] or [2, 3]
+- Only cite numbers of the given sources, and do not add a sources list yourself
+- Length requirement: %s
+
+OUTPUT: Only the synthesis, no meta-commentary.`, lengthInstruction)
+
+	if useMarkdown {
+		systemPrompt += "\n\nFormat the synthesis in markdown with ## sections for agreements, contradictions and unique points."
+	}
+
+	model, _ := config.ModelFor(TaskSummary)
+	sourceTokens := Max((config.ContextBudget(model)-2048)/Max(len(documents), 1), 256)
+
+	var builder strings.Builder
+	for i, doc := range documents {
+		builder.WriteString(fmt.Sprintf("[%d] %s\nURL: %s\nSummary:\n%s\n\n", i+1, doc.Title, doc.URL, truncateTokens(doc.Summary, sourceTokens)))
+	}
+	userPrompt := fmt.Sprintf("Compare these %d sources:\n\n%s", len(documents), strings.TrimSpace(builder.String()))
+
+	cacheManager := NewCacheManager(config)
+	cacheKey := cacheManager.PromptCacheKey("compare", TaskSummary, systemPrompt, userPrompt)
+	var cachedSynthesis string
+	if cacheManager.Get(cacheKey, &cachedSynthesis) {
+		DebugLog(config, "Cache hit for synthesis")
+		if stream != nil {
+			stream(cachedSynthesis)
+		}
+		return cachedSynthesis, nil
+	}
+
+	synthesis, err := callLLMStream(config, TaskSummary, systemPrompt, userPrompt, stream)
+	if err != nil {
+		return "", err
+	}
+
+	cacheManager.Set(cacheKey, synthesis, "")
+	return synthesis, nil
+}
+
+// runCompareCommand handles "hvsum compare <source>..." and returns the exit
+// code. Sources are extracted and summarized concurrently over the batch
+// worker pool; sources that fail are reported and left out.
+func runCompareCommand(inputs []string, config *Config, length string, enableSearch bool, output OutputOptions) int {
+	if len(inputs) < 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s compare <url|file> <url|file>...\n", appName)
+		return 1
+	}
+
+	SetConcurrencyLimits(config.LLMConcurrency, config.FetchConcurrency)
+	fmt.Fprintf(os.Stderr, "📚 Summarizing %d sources...\n", len(inputs))
+
+	// The synthesis works from detailed summaries, which also skips the
+	// length reduction of every source. Web search stays off for them, as
+	// its citations would collide with the numbering of the sources.
+	results := RunBatch(inputs, config, BatchOptions{
+		Length:      "detailed",
+		UseMarkdown: output.UseMarkdown,
+		Workers:     config.BatchWorkers,
+	})
+
+	var documents []SessionDocument
+	var sources []SearchResult
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		location := DetectSource(result.Input, nil).Location()
+		documents = append(documents, SessionDocument{
+			Title:   result.Title,
+			URL:     location,
+			Summary: StripCitations(result.Summary),
+			Content: result.Content,
+		})
+		sources = append(sources, SearchResult{Title: result.Title, URL: location})
+	}
+	if len(documents) < 2 {
+		fmt.Fprintf(os.Stderr, "Error: at least two sources are needed for a comparison, %d could be summarized\n", len(documents))
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "🧩 Synthesizing %d sources...\n", len(documents))
+
+	renderer, stream := newOutputStream(config, output.UseMarkdown)
+	citedStream, flushCitations := citationStream(stream, sources)
+	synthesis, err := SynthesizeSources(config, documents, length, output.UseMarkdown, citedStream)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	flushCitations()
+	synthesis = attachSources(synthesis, sources, output.UseMarkdown, stream)
+
+	output.Label = "Synthesis"
+	finishOutput(synthesis, renderer, config, output)
+
+	// Questions are answered from all of the documents
+	title := fmt.Sprintf("Comparison of %d sources", len(documents))
+	startQnA(NewSessionManager(config).NewMultiDocumentSession(title, synthesis, documents, enableSearch), config, output.UseMarkdown)
+	return 0
+}
@@ -0,0 +1,51 @@
+package main
+
+import "sync"
+
+// Process-wide concurrency limits for model calls and page fetches. They are
+// unlimited by default and bounded by batch mode and the API server, where many
+// summaries run at once.
+var (
+	limitsMu       sync.RWMutex
+	llmSemaphore   chan struct{}
+	fetchSemaphore chan struct{}
+)
+
+// SetConcurrencyLimits bounds concurrent model calls and fetches; zero means unlimited
+func SetConcurrencyLimits(llm, fetch int) {
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+
+	llmSemaphore = nil
+	if llm > 0 {
+		llmSemaphore = make(chan struct{}, llm)
+	}
+	fetchSemaphore = nil
+	if fetch > 0 {
+		fetchSemaphore = make(chan struct{}, fetch)
+	}
+}
+
+// acquireLLMSlot blocks until a model call may start and returns its release function
+func acquireLLMSlot() func() {
+	limitsMu.RLock()
+	semaphore := llmSemaphore
+	limitsMu.RUnlock()
+	return acquire(semaphore)
+}
+
+// acquireFetchSlot blocks until a fetch may start and returns its release function
+func acquireFetchSlot() func() {
+	limitsMu.RLock()
+	semaphore := fetchSemaphore
+	limitsMu.RUnlock()
+	return acquire(semaphore)
+}
+
+func acquire(semaphore chan struct{}) func() {
+	if semaphore == nil {
+		return func() {}
+	}
+	semaphore <- struct{}{}
+	return func() { <-semaphore }
+}
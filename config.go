@@ -5,76 +5,470 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // Config holds all user-configurable settings
 type Config struct {
-	DefaultModel  string `json:"default_model"`
-	DisablePager  bool   `json:"disable_pager"`
-	DisableQnA    bool   `json:"disable_qna"`
-	DebugMode     bool   `json:"debug_mode"`
-	SystemPrompts struct {
-		Summary     string `json:"summary"`
-		Question    string `json:"question"`
-		QnA         string `json:"qna"`
-		Markdown    string `json:"markdown"`
-		SearchQuery string `json:"search_query"`
-		SearchOnly  string `json:"search_only"`
-	} `json:"system_prompts"`
-	DefaultLength    string `json:"default_length"`
-	SessionPersist   bool   `json:"session_persist"`
-	MaxSearchResults int    `json:"max_search_results"`
-	CacheEnabled     bool   `json:"cache_enabled"`
-	CacheTTL         int    `json:"cache_ttl_hours"`
-}
-
-// LoadConfig loads or creates the configuration file
-func LoadConfig() (*Config, error) {
-	configDir, err := os.UserConfigDir()
-	if err != nil {
-		return nil, err
-	}
-	configPath := filepath.Join(configDir, appName, "config.json")
+	DefaultModel     string        `json:"default_model"`
+	DisablePager     bool          `json:"disable_pager"`
+	DisableQnA       bool          `json:"disable_qna"`
+	DebugMode        bool          `json:"debug_mode"`
+	DisableStreaming bool          `json:"disable_streaming"`
+	SystemPrompts    SystemPrompts `json:"system_prompts"`
+	DefaultLength    string        `json:"default_length"`
+	SessionPersist   bool          `json:"session_persist"`
+	MaxSearchResults int           `json:"max_search_results"`
+	CacheEnabled     bool          `json:"cache_enabled"`
+	CacheTTL         int           `json:"cache_ttl_hours"`
+	CacheBackend     string        `json:"cache_backend"`      // "file" or "sqlite"
+	CacheMaxSizeMB   int           `json:"cache_max_size_mb"`  // 0 for no limit
+	PageCacheSizeMB  int           `json:"page_cache_size_mb"` // Raw fetched pages, 0 for no limit
+	Offline          bool          `json:"offline"`            // No network access except the LLM
+	EnableSearch     bool          `json:"enable_search"`
+	Search           SearchConfig  `json:"search"`
+	LLM              LLMConfig     `json:"llm"`
+
+	// Sampling options sent with every generation request
+	Options map[string]interface{} `json:"options"`
+
+	// Per-task model and option overrides
+	Models ModelsConfig `json:"models"`
+
+	// Embedding retrieval over long documents during Q&A
+	Retrieval RetrievalConfig `json:"retrieval"`
+
+	// Record every summarized source in the knowledge base (hvsum kb)
+	KnowledgeBase bool `json:"knowledge_base"`
+
+	// Named profiles selected with --profile
+	Profiles      map[string]Profile `json:"profiles"`
+	ActiveProfile string             `json:"-"`
+
+	// Context window budgets in tokens, keyed by model name
+	ContextBudgets       map[string]int `json:"context_budgets"`
+	DefaultContextTokens int            `json:"default_context_tokens"`
+	MaxParallelChunks    int            `json:"max_parallel_chunks"`
+
+	// Batch mode concurrency
+	BatchWorkers     int `json:"batch_workers"`
+	LLMConcurrency   int `json:"llm_concurrency"`
+	FetchConcurrency int `json:"fetch_concurrency"`
+
+	// Config files that were merged, in load order
+	files []string
+}
+
+// SystemPrompts holds the prompts used for each kind of request
+type SystemPrompts struct {
+	Summary     string `json:"summary"`
+	Question    string `json:"question"`
+	QnA         string `json:"qna"`
+	Markdown    string `json:"markdown"`
+	SearchQuery string `json:"search_query"`
+	SearchOnly  string `json:"search_only"`
+}
+
+// Profile overrides a subset of the configuration. Empty fields leave the
+// base configuration untouched.
+type Profile struct {
+	DefaultModel     string                 `json:"default_model,omitempty"`
+	DefaultLength    string                 `json:"default_length,omitempty"`
+	SystemPrompts    SystemPrompts          `json:"system_prompts"`
+	EnableSearch     *bool                  `json:"enable_search,omitempty"`
+	MaxSearchResults int                    `json:"max_search_results,omitempty"`
+	Options          map[string]interface{} `json:"options,omitempty"`
+}
+
+// Task identifies a kind of model call so it can use its own model and options
+type Task string
+
+const (
+	TaskSummary     Task = "summary"      // Detailed summaries, including long-document chunks
+	TaskLength      Task = "length"       // Length reduction of the detailed summary
+	TaskSearchQuery Task = "search_query" // Search query generation
+	TaskOutline     Task = "outline"      // Outline generation
+	TaskQnA         Task = "qna"          // Interactive questions and answers
+)
+
+// ModelConfig overrides the model and options for one task. An empty model
+// uses DefaultModel; options are merged over the global options.
+type ModelConfig struct {
+	Model   string                 `json:"model,omitempty"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+// ModelsConfig assigns a model configuration to each task
+type ModelsConfig struct {
+	Summary     ModelConfig `json:"summary"`
+	Length      ModelConfig `json:"length"`
+	SearchQuery ModelConfig `json:"search_query"`
+	Outline     ModelConfig `json:"outline"`
+	QnA         ModelConfig `json:"qna"`
+}
+
+// SearchConfig selects the web search backend
+type SearchConfig struct {
+	Engines []string      `json:"engines"` // In order of preference: "duckduckgo", "searxng"
+	Mode    string        `json:"mode"`    // "fallback" tries engines in turn, "fusion" queries all
+	SearXNG SearXNGConfig `json:"searxng"`
+
+	// Deep search reads the top result pages instead of only their snippets
+	DeepSearch       bool `json:"deep_search"`
+	DeepPages        int  `json:"deep_pages"`
+	PageBudgetTokens int  `json:"page_budget_tokens"` // Excerpt size per page
+}
+
+// SearXNGConfig points at a self-hosted SearXNG instance with the JSON format enabled
+type SearXNGConfig struct {
+	BaseURL    string `json:"base_url"`   // e.g. http://localhost:8888
+	Categories string `json:"categories"` // Comma-separated, e.g. "general,news"
+	Language   string `json:"language"`   // e.g. "en" or "all"
+	SafeSearch int    `json:"safesearch"` // 0 off, 1 moderate, 2 strict
+}
+
+// RetrievalConfig controls how documents are chunked, embedded and searched
+type RetrievalConfig struct {
+	EmbeddingModel string `json:"embedding_model"`
+	ChunkTokens    int    `json:"chunk_tokens"`
+	TopK           int    `json:"top_k"`
+}
+
+// LLMConfig selects and configures the model backend
+type LLMConfig struct {
+	Provider string `json:"provider"` // "ollama" or "openai"
+	BaseURL  string `json:"base_url"` // Empty uses OLLAMA_HOST or http://localhost:8080/v1
+	APIKey   string `json:"api_key"`  // Only used by the openai provider
+}
+
+// envPrefix is the prefix of environment variables that override config keys
+const envPrefix = "HVSUM_"
 
+// LoadConfig builds the configuration in layers: built-in defaults, the user
+// config file, the file given with --config and finally HVSUM_* environment
+// variables. Keys missing from a file keep the value of the layer below it,
+// so older config files still pick up new settings. CLI flags are applied on
+// top by the caller.
+func LoadConfig(customPath string) (*Config, error) {
+	config := createDefaultConfig()
+
+	configPath := getConfigPath()
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		fmt.Fprintf(os.Stderr, "Creating default configuration at: %s\n", configPath)
-		defaultConfig := createDefaultConfig()
-		if err := saveConfig(configPath, defaultConfig); err != nil {
+		if err := saveConfig(configPath, config); err != nil {
 			return nil, fmt.Errorf("could not create default config: %w", err)
 		}
-		return defaultConfig, nil
+	} else if err := config.mergeFile(configPath); err != nil {
+		return nil, err
+	}
+
+	if customPath != "" {
+		if err := config.mergeFile(customPath); err != nil {
+			return nil, err
+		}
 	}
 
-	file, err := os.Open(configPath)
-	if err != nil {
+	if err := config.applyEnv(os.Environ()); err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	var config Config
-	if err := json.NewDecoder(file).Decode(&config); err != nil {
-		return nil, fmt.Errorf("config file is corrupted: %w", err)
+	return config, nil
+}
+
+// mergeFile overlays the keys present in a JSON config file onto the config
+// and warns about keys that don't correspond to any setting
+func (c *Config) mergeFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("config file %s is corrupted: %w", path, err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err == nil {
+		for _, key := range unknownKeys(raw, reflect.TypeOf(*c), "") {
+			fmt.Fprintf(os.Stderr, "Warning: unknown config key '%s' in %s\n", key, path)
+		}
+	}
+
+	c.files = append(c.files, path)
+	return nil
+}
+
+// unknownKeys returns the dotted paths of keys in raw that have no matching
+// json tag in the struct type t
+func unknownKeys(raw map[string]interface{}, t reflect.Type, prefix string) []string {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = field.Type
+		}
+	}
+
+	var unknown []string
+	for key, value := range raw {
+		fieldType, ok := fields[key]
+		if !ok {
+			unknown = append(unknown, prefix+key)
+			continue
+		}
+		nested, isObject := value.(map[string]interface{})
+		if !isObject {
+			continue
+		}
+		switch {
+		case fieldType.Kind() == reflect.Struct:
+			unknown = append(unknown, unknownKeys(nested, fieldType, prefix+key+".")...)
+		case fieldType.Kind() == reflect.Map && fieldType.Elem().Kind() == reflect.Struct:
+			// Named entries such as profiles.quick
+			for name, entry := range nested {
+				if entryObject, ok := entry.(map[string]interface{}); ok {
+					unknown = append(unknown, unknownKeys(entryObject, fieldType.Elem(), prefix+key+"."+name+".")...)
+				}
+			}
+		}
+	}
+
+	sort.Strings(unknown)
+	return unknown
+}
+
+// applyEnv overrides scalar settings from environment variables named after
+// their json keys, e.g. HVSUM_DEFAULT_MODEL or HVSUM_LLM_BASE_URL
+func (c *Config) applyEnv(environ []string) error {
+	env := make(map[string]string)
+	for _, entry := range environ {
+		if key, value, found := strings.Cut(entry, "="); found && strings.HasPrefix(key, envPrefix) {
+			env[key] = value
+		}
+	}
+	if len(env) == 0 {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	if err := applyEnvFields(reflect.ValueOf(c).Elem(), envPrefix, env, known); err != nil {
+		return err
+	}
+
+	for key := range env {
+		if !known[key] {
+			fmt.Fprintf(os.Stderr, "Warning: unknown config environment variable %s\n", key)
+		}
+	}
+	return nil
+}
+
+func applyEnvFields(v reflect.Value, prefix string, env map[string]string, known map[string]bool) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		envName := prefix + strings.ToUpper(name)
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Struct {
+			if err := applyEnvFields(field, envName+"_", env, known); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := env[envName]
+		if !ok {
+			continue
+		}
+		known[envName] = true
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Bool:
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s: %q is not a boolean", envName, value)
+			}
+			field.SetBool(parsed)
+		case reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.String {
+				return fmt.Errorf("%s cannot be set from the environment", envName)
+			}
+			// Comma-separated lists, e.g. HVSUM_SEARCH_ENGINES=searxng,duckduckgo
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+		case reflect.Int:
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s: %q is not a number", envName, value)
+			}
+			field.SetInt(int64(parsed))
+		default:
+			return fmt.Errorf("%s cannot be set from the environment", envName)
+		}
+	}
+	return nil
+}
+
+// ApplyProfile overlays the named profile onto the configuration
+func (c *Config) ApplyProfile(name string) error {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		var available []string
+		for profileName := range c.Profiles {
+			available = append(available, profileName)
+		}
+		sort.Strings(available)
+		if len(available) == 0 {
+			return fmt.Errorf("unknown profile '%s' (no profiles are configured)", name)
+		}
+		return fmt.Errorf("unknown profile '%s' (available: %s)", name, strings.Join(available, ", "))
+	}
+
+	if profile.DefaultModel != "" {
+		c.DefaultModel = profile.DefaultModel
+	}
+	if profile.DefaultLength != "" {
+		c.DefaultLength = profile.DefaultLength
+	}
+	if profile.EnableSearch != nil {
+		c.EnableSearch = *profile.EnableSearch
+	}
+	if profile.MaxSearchResults > 0 {
+		c.MaxSearchResults = profile.MaxSearchResults
+	}
+
+	// Override only the prompts the profile sets
+	prompts := reflect.ValueOf(&c.SystemPrompts).Elem()
+	overrides := reflect.ValueOf(profile.SystemPrompts)
+	for i := 0; i < overrides.NumField(); i++ {
+		if value := overrides.Field(i).String(); value != "" {
+			prompts.Field(i).SetString(value)
+		}
 	}
 
-	return &config, nil
+	if len(profile.Options) > 0 {
+		options := make(map[string]interface{}, len(c.Options)+len(profile.Options))
+		for key, value := range c.Options {
+			options[key] = value
+		}
+		for key, value := range profile.Options {
+			options[key] = value
+		}
+		c.Options = options
+	}
+
+	c.ActiveProfile = name
+	return nil
 }
 
 // Print displays the current configuration
 func (c *Config) Print() {
 	fmt.Printf("Current Configuration:\n")
+	if c.ActiveProfile != "" {
+		fmt.Printf("Profile: %s\n", c.ActiveProfile)
+	}
 	fmt.Printf("Model: %s\n", c.DefaultModel)
 	fmt.Printf("Default Length: %s\n", c.DefaultLength)
 	fmt.Printf("Disable Pager: %t\n", c.DisablePager)
 	fmt.Printf("Disable Q&A: %t\n", c.DisableQnA)
 	fmt.Printf("Debug Mode: %t\n", c.DebugMode)
+	fmt.Printf("Disable Streaming: %t\n", c.DisableStreaming)
 	fmt.Printf("Session Persist: %t\n", c.SessionPersist)
 	fmt.Printf("Max Search Results: %d\n", c.MaxSearchResults)
 	fmt.Printf("Cache Enabled: %t\n", c.CacheEnabled)
 	fmt.Printf("Cache TTL: %d hours\n", c.CacheTTL)
+	fmt.Printf("Cache Backend: %s (max %d MB)\n", c.CacheBackend, c.CacheMaxSizeMB)
+	fmt.Printf("Page Cache: max %d MB\n", c.PageCacheSizeMB)
+	fmt.Printf("Offline: %t\n", c.Offline)
+	fmt.Printf("Search Engines: %s (%s)\n", strings.Join(c.Search.Engines, ", "), c.Search.Mode)
+	fmt.Printf("LLM Provider: %s\n", c.LLM.Provider)
+	fmt.Printf("Context Budget: %d tokens\n", c.ContextBudget(c.DefaultModel))
+	for _, task := range []Task{TaskSummary, TaskLength, TaskSearchQuery, TaskOutline, TaskQnA} {
+		if model, _ := c.ModelFor(task); model != c.DefaultModel {
+			fmt.Printf("Model (%s): %s\n", task, model)
+		}
+	}
 	fmt.Printf("Config Location: %s\n", getConfigPath())
+	if len(c.files) > 0 {
+		fmt.Printf("Loaded Files: %s\n", strings.Join(c.files, ", "))
+	}
 	fmt.Printf("\nAvailable lengths: short, medium, long, detailed\n")
 }
 
+// ContextBudget returns the context window in tokens for a model, matching
+// either the full name ("gemma3:12b") or its base name ("gemma3")
+func (c *Config) ContextBudget(model string) int {
+	if budget, ok := c.ContextBudgets[model]; ok && budget > 0 {
+		return budget
+	}
+	if base, _, found := strings.Cut(model, ":"); found {
+		if budget, ok := c.ContextBudgets[base]; ok && budget > 0 {
+			return budget
+		}
+	}
+	if c.DefaultContextTokens > 0 {
+		return c.DefaultContextTokens
+	}
+	return 8192
+}
+
+// ModelFor returns the model and generation options to use for a task. The
+// options start with the model's context budget (num_ctx), then the global
+// options, then the task's own options, e.g. temperature, seed, top_k or stop.
+func (c *Config) ModelFor(task Task) (string, map[string]interface{}) {
+	var taskConfig ModelConfig
+	switch task {
+	case TaskSummary:
+		taskConfig = c.Models.Summary
+	case TaskLength:
+		taskConfig = c.Models.Length
+	case TaskSearchQuery:
+		taskConfig = c.Models.SearchQuery
+	case TaskOutline:
+		taskConfig = c.Models.Outline
+	case TaskQnA:
+		taskConfig = c.Models.QnA
+	}
+
+	model := taskConfig.Model
+	if model == "" {
+		model = c.DefaultModel
+	}
+
+	options := map[string]interface{}{
+		"num_ctx": c.ContextBudget(model),
+	}
+	for key, value := range c.Options {
+		options[key] = value
+	}
+	for key, value := range taskConfig.Options {
+		options[key] = value
+	}
+
+	return model, options
+}
+
+// ChunkTokens returns how much of a model's context can hold document text,
+// leaving room for the system prompt, instructions and the response
+func (c *Config) ChunkTokens(model string) int {
+	return Max(c.ContextBudget(model)/2, 512)
+}
+
 func createDefaultConfig() *Config {
 	return &Config{
 		DefaultModel:     "gemma3",
@@ -86,14 +480,45 @@ func createDefaultConfig() *Config {
 		MaxSearchResults: 8,
 		CacheEnabled:     true,
 		CacheTTL:         24,
-		SystemPrompts: struct {
-			Summary     string `json:"summary"`
-			Question    string `json:"question"`
-			QnA         string `json:"qna"`
-			Markdown    string `json:"markdown"`
-			SearchQuery string `json:"search_query"`
-			SearchOnly  string `json:"search_only"`
-		}{
+		CacheBackend:     "file",
+		CacheMaxSizeMB:   256,
+		PageCacheSizeMB:  512,
+		Search: SearchConfig{
+			Engines:          []string{"duckduckgo"},
+			Mode:             "fallback",
+			DeepPages:        3,
+			PageBudgetTokens: 800,
+			SearXNG: SearXNGConfig{
+				Categories: "general",
+				Language:   "all",
+				SafeSearch: 1,
+			},
+		},
+		LLM: LLMConfig{
+			Provider: "ollama",
+		},
+		Options: map[string]interface{}{
+			"temperature": 0.1, // Lower temperature for more consistent summaries
+			"top_p":       0.9,
+		},
+		Profiles:      map[string]Profile{},
+		KnowledgeBase: true,
+		Retrieval: RetrievalConfig{
+			EmbeddingModel: "nomic-embed-text",
+			ChunkTokens:    300,
+			TopK:           5,
+		},
+		ContextBudgets: map[string]int{
+			"gemma3":   8192,
+			"llama3.1": 8192,
+			"qwen2.5":  8192,
+		},
+		DefaultContextTokens: 8192,
+		MaxParallelChunks:    3,
+		BatchWorkers:         4,
+		LLMConcurrency:       2,
+		FetchConcurrency:     8,
+		SystemPrompts: SystemPrompts{
 			Summary: `You are an expert content summarizer. Create clear, concise summaries that capture essential information.
 
 CORE RULES:
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// FetchResultPages downloads the top search result pages concurrently and
+// replaces their snippets with the excerpts most relevant to focus. Pages
+// that fail to load keep their snippet.
+func (sm *SearchManager) FetchResultPages(results []SearchResult, focus string) []SearchResult {
+	pages := Min(sm.config.Search.DeepPages, len(results))
+	if pages <= 0 {
+		return results
+	}
+
+	fmt.Fprintf(os.Stderr, "📖 Reading %d search result pages...\n", pages)
+
+	enriched := append([]SearchResult(nil), results...)
+	var wg sync.WaitGroup
+	for i := 0; i < pages; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// The page cache of ExtractWebContent lets different questions
+			// pick different excerpts without downloading a page again
+			content, _, err := ExtractWebContent(enriched[i].URL, sm.config)
+			if err != nil {
+				DebugLog(sm.config, "Could not read %s: %v", enriched[i].URL, err)
+				return
+			}
+			enriched[i].Content = selectExcerpts(content, focus, sm.config.Search.PageBudgetTokens)
+		}(i)
+	}
+	wg.Wait()
+
+	return enriched
+}
+
+// selectExcerpts picks the passages of content that mention the most focus
+// terms, up to budgetTokens, and returns them in document order. Pages
+// without any matching passage fall back to their opening text.
+func selectExcerpts(content, focus string, budgetTokens int) string {
+	if budgetTokens <= 0 || EstimateTokens(content) <= budgetTokens {
+		return content
+	}
+
+	passages := ChunkText(content, Max(budgetTokens/4, 64), 0)
+	terms := focusTerms(focus)
+
+	type scoredPassage struct {
+		index int
+		score int
+	}
+	scored := make([]scoredPassage, len(passages))
+	for i, passage := range passages {
+		lower := strings.ToLower(passage)
+		score := 0
+		for _, term := range terms {
+			if strings.Contains(lower, term) {
+				// Distinct terms count more than repetitions of one term
+				score += 10 + strings.Count(lower, term)
+			}
+		}
+		scored[i] = scoredPassage{index: i, score: score}
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+
+	var chosen []int
+	used := 0
+	for _, passage := range scored {
+		if passage.score == 0 && len(chosen) > 0 && scored[0].score > 0 {
+			break // Don't pad relevant excerpts with unrelated text
+		}
+		tokens := EstimateTokens(passages[passage.index])
+		if used+tokens > budgetTokens {
+			continue
+		}
+		chosen = append(chosen, passage.index)
+		used += tokens
+	}
+	sort.Ints(chosen)
+
+	var excerpts []string
+	for i, index := range chosen {
+		if i > 0 && index != chosen[i-1]+1 {
+			excerpts = append(excerpts, "[...]")
+		}
+		excerpts = append(excerpts, passages[index])
+	}
+	return strings.Join(excerpts, "\n")
+}
+
+// focusTerms returns the distinct lowercase words of focus worth matching
+func focusTerms(focus string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(focus), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) < 4 || seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+	return terms
+}
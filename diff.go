@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxDiffCells bounds the LCS table; larger changed regions are reported as
+// a wholesale replacement instead
+const maxDiffCells = 4_000_000
+
+// diffContextLines is how many unchanged lines surround each hunk
+const diffContextLines = 2
+
+// DiffLine is one line of a line-based diff: ' ' unchanged, '-' removed, '+' added
+type DiffLine struct {
+	Op   byte
+	Text string
+}
+
+// splitDiffLines prepares extracted text for diffing. Blank lines and
+// trailing whitespace carry no meaning after extraction, so they are dropped.
+func splitDiffLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimRight(line, " \t\r"); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// DiffLines computes a line diff from a to b using the longest common
+// subsequence of the region between the common prefix and suffix
+func DiffLines(a, b []string) []DiffLine {
+	var result []DiffLine
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, line := range a[:prefix] {
+		result = append(result, DiffLine{' ', line})
+	}
+	result = append(result, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		result = append(result, DiffLine{' ', line})
+	}
+	return result
+}
+
+// diffMiddle diffs the changed region with a dynamic programming LCS table
+func diffMiddle(a, b []string) []DiffLine {
+	var result []DiffLine
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			result = append(result, DiffLine{'-', line})
+		}
+		for _, line := range b {
+			result = append(result, DiffLine{'+', line})
+		}
+		return result
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, DiffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, DiffLine{'-', a[i]})
+			i++
+		default:
+			result = append(result, DiffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		result = append(result, DiffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		result = append(result, DiffLine{'+', b[j]})
+	}
+	return result
+}
+
+// FormatDiff renders the changed lines with a little surrounding context,
+// separating distant hunks with "@@". It returns "" when nothing changed.
+func FormatDiff(lines []DiffLine, contextLines int) string {
+	show := make([]bool, len(lines))
+	for i, line := range lines {
+		if line.Op == ' ' {
+			continue
+		}
+		for k := Max(i-contextLines, 0); k <= Min(i+contextLines, len(lines)-1); k++ {
+			show[k] = true
+		}
+	}
+
+	var builder strings.Builder
+	last := -1
+	for i, line := range lines {
+		if !show[i] {
+			continue
+		}
+		if last != i-1 || last == -1 {
+			builder.WriteString("@@\n")
+		}
+		builder.WriteByte(line.Op)
+		builder.WriteString(line.Text)
+		builder.WriteByte('\n')
+		last = i
+	}
+	return builder.String()
+}
+
+// diffStats counts added and removed lines
+func diffStats(lines []DiffLine) (added, removed int) {
+	for _, line := range lines {
+		switch line.Op {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// SummarizeDiff explains a line diff between two versions at the given length
+func SummarizeDiff(config *Config, titleA, titleB, diff, length string, useMarkdown bool, stream StreamFunc) (string, error) {
+	lengthInstruction, exists := lengthMap[length]
+	if !exists {
+		lengthInstruction = lengthMap["medium"]
+	}
+
+	systemPrompt := fmt.Sprintf(`You explain how a document changed between two versions, working from a line diff.
+
+In the diff, lines starting with "-" were removed, lines starting with "+" were added and lines starting with a space are unchanged context. "@@" separates distant parts of the document. A removed line followed by a similar added line is a modification.
+
+Rules:
+1. Cover additions, removals and changed semantics: what a modified statement means now compared to before
+2. Lead with the changes that matter most to a reader of the document
+3. Quote exact values (numbers, dates, names, obligations) where they changed
+4. Ignore changes that are only formatting or wording with the same meaning
+5. Length requirement: %s
+
+OUTPUT: Only the summary of changes, no meta-commentary.`, lengthInstruction)
+
+	if useMarkdown {
+		systemPrompt += "\n\nFormat the summary in markdown, with sections for additions, removals and changes where there are any."
+	}
+
+	model, _ := config.ModelFor(TaskSummary)
+	diffTokens := Max(config.ContextBudget(model)-1536, 1024)
+
+	userPrompt := fmt.Sprintf(`Version A: %s
+Version B: %s
+
+Diff from A to B:
+%s`, titleA, titleB, truncateTokens(diff, diffTokens))
+
+	cacheManager := NewCacheManager(config)
+	cacheKey := cacheManager.PromptCacheKey("diff", TaskSummary, systemPrompt, userPrompt)
+	var cachedSummary string
+	if cacheManager.Get(cacheKey, &cachedSummary) {
+		DebugLog(config, "Cache hit for diff summary")
+		return cachedSummary, nil
+	}
+
+	summary, err := callLLMStream(config, TaskSummary, systemPrompt, userPrompt, stream)
+	if err != nil {
+		return "", err
+	}
+
+	cacheManager.Set(cacheKey, summary, "")
+	return summary, nil
+}
+
+// extractForDiff extracts one side of a diff through the regular pipeline
+func extractForDiff(input string, config *Config) (source ContentSource, content, title string, err error) {
+	source = DetectSource(input, config)
+	if source == nil {
+		return nil, "", "", fmt.Errorf("'%s' is not a URL or file", input)
+	}
+
+	if source.IsRemote() {
+		fmt.Fprintf(os.Stderr, "🌐 Fetching content from: %s\n", source.Location())
+	} else {
+		fmt.Fprintf(os.Stderr, "📄 Reading content from: %s\n", source.Location())
+	}
+	content, title, err = source.Extract()
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to extract %s: %v", input, err)
+	}
+	return source, content, title, nil
+}
+
+// runDiffCommand handles "hvsum diff <A> <B>" and returns the exit code
+func runDiffCommand(args []string, config *Config, length string, enableSearch bool, output OutputOptions) int {
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s diff <url|file A> <url|file B>\n", appName)
+		return 1
+	}
+	if args[0] == "-" && args[1] == "-" {
+		fmt.Fprintf(os.Stderr, "Error: only one side can be read from stdin\n")
+		return 1
+	}
+
+	sourceA, contentA, titleA, err := extractForDiff(args[0], config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	sourceB, contentB, titleB, err := extractForDiff(args[1], config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	lines := DiffLines(splitDiffLines(contentA), splitDiffLines(contentB))
+	diff := FormatDiff(lines, diffContextLines)
+	if diff == "" {
+		fmt.Println("✅ No differences in the extracted text.")
+		return 0
+	}
+
+	added, removed := diffStats(lines)
+	fmt.Fprintf(os.Stderr, "🔀 %d lines added, %d removed, summarizing changes...\n", added, removed)
+	DebugLog(config, "Diff:\n%s", diff)
+
+	renderer, stream := newOutputStream(config, output.UseMarkdown)
+	summary, err := SummarizeDiff(config, titleA, titleB, diff, length, output.UseMarkdown, stream)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	finishOutput(summary, renderer, config, output)
+
+	// Questions are answered from both versions and the diff between them
+	session := NewSessionManager(config).NewSession(fmt.Sprintf("Changes from %s to %s", titleA, titleB), summary, enableSearch)
+	session.ContextContent = fmt.Sprintf("VERSION A: %s (%s)\n\n%s\n\nVERSION B: %s (%s)\n\n%s\n\nDIFF FROM A TO B:\n%s",
+		titleA, sourceA.Location(), contentA, titleB, sourceB.Location(), contentB, diff)
+	startQnA(session, config, output.UseMarkdown)
+	return 0
+}
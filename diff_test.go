@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// renderDiff writes diff lines compactly, e.g. " a|-b|+c"
+func renderDiff(lines []DiffLine) string {
+	parts := make([]string, len(lines))
+	for i, line := range lines {
+		parts[i] = string(line.Op) + line.Text
+	}
+	return strings.Join(parts, "|")
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"identical", "a b c", "a b c", " a| b| c"},
+		{"both empty", "", "", ""},
+		{"insertion", "a b c", "a x b c", " a|+x| b| c"},
+		{"deletion", "a b c", "a c", " a|-b| c"},
+		{"modification", "a b c", "a B c", " a|-b|+B| c"},
+		{"from empty", "", "a b", "+a|+b"},
+		{"to empty", "a b", "", "-a|-b"},
+		{"append", "a b", "a b c", " a| b|+c"},
+		{"swap", "x a b y", "x b a y", " x|-a| b|+a| y"},
+		{"two changes", "a b c d e", "a B c d E", " a|-b|+B| c| d|-e|+E"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := renderDiff(DiffLines(strings.Fields(tt.a), strings.Fields(tt.b)))
+			if got != tt.want {
+				t.Errorf("DiffLines(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesFallsBackOnLargeInputs(t *testing.T) {
+	// Entirely different inputs past maxDiffCells skip the LCS table
+	var a, b []string
+	for i := 0; i < 2001; i++ {
+		a = append(a, fmt.Sprintf("old %d", i))
+		b = append(b, fmt.Sprintf("new %d", i))
+	}
+	if len(a)*len(b) <= maxDiffCells {
+		t.Fatalf("inputs do not exceed maxDiffCells")
+	}
+
+	lines := DiffLines(a, b)
+	if len(lines) != len(a)+len(b) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(a)+len(b))
+	}
+	if lines[0] != (DiffLine{'-', "old 0"}) || lines[len(a)] != (DiffLine{'+', "new 0"}) {
+		t.Errorf("want all removals before all additions, got %v and %v", lines[0], lines[len(a)])
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	numbered := func(n int) []string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("l%d", i)
+		}
+		return lines
+	}
+	with := func(lines []string, changes map[int]string) []string {
+		changed := append([]string(nil), lines...)
+		for i, text := range changes {
+			changed[i] = text
+		}
+		return changed
+	}
+
+	base := numbered(20)
+	tests := []struct {
+		name    string
+		changed []string
+		want    string
+	}{
+		{"no change", base, ""},
+		{"first line", with(base, map[int]string{0: "X"}), "@@\n-l0\n+X\n l1\n l2\n"},
+		{"last line", with(base, map[int]string{19: "X"}), "@@\n l17\n l18\n-l19\n+X\n"},
+		{"distant hunks", with(base, map[int]string{2: "X", 15: "Y"}),
+			"@@\n l0\n l1\n-l2\n+X\n l3\n l4\n@@\n l13\n l14\n-l15\n+Y\n l16\n l17\n"},
+		{"overlapping context merges", with(base, map[int]string{2: "X", 6: "Y"}),
+			"@@\n l0\n l1\n-l2\n+X\n l3\n l4\n l5\n-l6\n+Y\n l7\n l8\n"},
+		{"insertion", append(append(base[:10:10], "new"), base[10:]...), "@@\n l8\n l9\n+new\n l10\n l11\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatDiff(DiffLines(base, tt.changed), 2)
+			if got != tt.want {
+				t.Errorf("FormatDiff = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitDiffLines(t *testing.T) {
+	got := splitDiffLines("first  \n\n   \nsecond\r\n\tindented\t\n")
+	want := []string{"first", "second", "\tindented"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("splitDiffLines = %q, want %q", got, want)
+	}
+}
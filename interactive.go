@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -22,9 +23,9 @@ func StartInteractiveSession(session *SessionData, config *Config, renderMarkdow
 	}
 	DebugLog(config, "Starting enhanced interactive session for: %s", session.ID)
 
-	client, err := api.ClientFromEnvironment()
+	provider, err := NewLLMProvider(config)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "❌ Could not connect to Ollama: %v\n", err)
+		fmt.Fprintf(os.Stderr, "❌ Could not initialize model provider: %v\n", err)
 		return
 	}
 
@@ -89,16 +90,26 @@ func StartInteractiveSession(session *SessionData, config *Config, renderMarkdow
 
 		DebugLog(config, "Processing question: %s", question)
 
-		// Show thinking indicator
+		// Show thinking indicator until the first token arrives
 		thinkingMsg := "🤔 Processing"
 		stopDots := StartThinkingDots(thinkingMsg)
 
+		var renderer *StreamRenderer
+		var stream StreamFunc
+		if !config.DisableStreaming {
+			renderer = NewStreamRenderer(renderMarkdown)
+			renderer.BeforeFirstToken(func() {
+				stopDots()
+				fmt.Fprintf(os.Stderr, "\n")
+			})
+			stream = renderer.Write
+		}
+
 		// Generate response with caching
-		response, err := generateEnhancedResponse(question, currentSession, config, client, searchManager, cacheManager, enableSearch)
+		response, err := generateEnhancedResponse(context.Background(), question, currentSession, config, provider, searchManager, cacheManager, enableSearch, stream)
 
-		close(stopDots)
-		// Ensure the line is fully cleared before printing the response
-		fmt.Fprintf(os.Stderr, "\r\033[K")
+		stopDots()
+		streamed := renderer != nil && renderer.Finish()
 
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
@@ -109,9 +120,11 @@ func StartInteractiveSession(session *SessionData, config *Config, renderMarkdow
 		sessionManager.AddMessage(currentSession, "user", question)
 		sessionManager.AddMessage(currentSession, "assistant", response)
 
-		// Display response
-		fmt.Fprintf(os.Stderr, "\n")
-		RenderToConsole(response, renderMarkdown)
+		// Display response unless it was already streamed
+		if !streamed {
+			fmt.Fprintf(os.Stderr, "\n")
+			RenderToConsole(response, renderMarkdown)
+		}
 		fmt.Fprintf(os.Stderr, "\n")
 	}
 
@@ -298,9 +311,11 @@ func cleanSessionName(name string) string {
 }
 
 // generateEnhancedResponse creates a response with intelligent search fallback
-func generateEnhancedResponse(question string, session *SessionData, config *Config, client *api.Client, searchManager *SearchManager, cacheManager *CacheManager, enableSearch bool) (string, error) {
+func generateEnhancedResponse(ctx context.Context, question string, session *SessionData, config *Config, provider LLMProvider, searchManager *SearchManager, cacheManager *CacheManager, enableSearch bool, stream StreamFunc) (string, error) {
 	// Check cache first
-	cacheKey := cacheManager.GetCacheKey(fmt.Sprintf("qa:%s:%s", question, session.InitialSummary[:Min(100, len(session.InitialSummary))]))
+	model, options := config.ModelFor(TaskQnA)
+	cacheKey := cacheManager.GetCacheKey("qa", model, options, config.SystemPrompts.QnA, config.Retrieval,
+		question, session.InitialSummary, contentHash(session.Content()), enableSearch)
 	var cachedResponse string
 	if cacheManager.Get(cacheKey, &cachedResponse) {
 		DebugLog(config, "Cache hit for Q&A")
@@ -314,12 +329,19 @@ func generateEnhancedResponse(question string, session *SessionData, config *Con
 	documentContext := fmt.Sprintf(`DOCUMENT SUMMARY:
 %s
 
-FULL DOCUMENT CONTEXT:
+RELEVANT DOCUMENT EXCERPTS:
 %s
 
 ---
 
-Based ONLY on the above document content, answer the following question. If the answer is not in the document, respond with exactly: "SEARCH_NEEDED: [brief description of what information is missing]"`, session.InitialSummary, session.ContextContent[:Min(2000, len(session.ContextContent))])
+Based ONLY on the above document content, answer the following question. If the answer is not in the document, respond with exactly: "SEARCH_NEEDED: [brief description of what information is missing]"`, session.InitialSummary, RetrieveContext(ctx, question, session, config, provider))
+
+	if hasPageMarkers(session.Content()) {
+		documentContext += "\n\n" + pageCitationInstruction
+	}
+	if len(session.Documents) > 0 {
+		documentContext += "\n\n" + sourceAttributionInstruction
+	}
 
 	// Build conversation context for pronoun resolution
 	conversationContext := ""
@@ -353,7 +375,7 @@ QUESTION: %s
 
 INSTRUCTIONS: Your task is to answer the user's QUESTION using the provided context.
 
-1. First, evaluate if the "DOCUMENT SUMMARY", "FULL DOCUMENT CONTEXT", and "RECENT CONVERSATION CONTEXT" contain enough information to fully and comprehensively answer the question.
+1. First, evaluate if the "DOCUMENT SUMMARY", "RELEVANT DOCUMENT EXCERPTS", and "RECENT CONVERSATION CONTEXT" contain enough information to fully and comprehensively answer the question.
 2. Pay close attention to requests for more detail, elaboration, or specific information. If the user asks for more detail (e.g., "in a couple of paragraphs") and the context only provides a brief summary, you must treat the context as insufficient.
 3. If the context is insufficient to provide a detailed, comprehensive answer that meets the user's request, you MUST respond with ONLY the string "SEARCH_NEEDED: [a concise search query to find the missing information]". Do not provide a partial or summary answer from the existing context in this case.
 4. If the context IS sufficient, provide a complete and comprehensive answer based on the provided information.`, documentContext, conversationContext, question)
@@ -363,26 +385,23 @@ INSTRUCTIONS: Your task is to answer the user's QUESTION using the provided cont
 		{Role: "user", Content: userPrompt},
 	}
 
-	// Generate initial response
-	isStreaming := !config.DisablePager
-	req := &api.ChatRequest{
-		Model:    config.DefaultModel,
-		Messages: messages,
-		Stream:   &isStreaming,
+	// Generate initial response, never showing a SEARCH_NEEDED reply
+	var initialStream StreamFunc
+	var gate *prefixGate
+	if stream != nil {
+		gate = newPrefixGate("SEARCH_NEEDED:", stream)
+		initialStream = gate.Write
 	}
 
-	var responseBuilder strings.Builder
-	err := client.Chat(context.Background(), req, func(resp api.ChatResponse) error {
-		content := resp.Message.Content
-		responseBuilder.WriteString(content)
-		return nil
-	})
-
+	rawResponse, err := provider.Chat(ctx, model, messages, options, initialStream)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate response: %v", err)
 	}
+	if gate != nil {
+		gate.Flush()
+	}
 
-	initialResponse := strings.TrimSpace(responseBuilder.String())
+	initialResponse := strings.TrimSpace(rawResponse)
 
 	// Determine if a search is needed based on the initial response.
 	var needsSearch bool
@@ -423,8 +442,9 @@ INSTRUCTIONS: Your task is to answer the user's QUESTION using the provided cont
 			}
 		}
 
-		searchContext := fmt.Sprintf("%s.%s Question: %s", session.ContextContent[:Min(600, len(session.ContextContent))], recentContext, question)
-		searchQueries, err := generateSearchQueries(config, searchContext, fmt.Sprintf("find information to answer: %s", question), session.ID)
+		content := session.Content()
+		searchContext := fmt.Sprintf("%s.%s Question: %s", content[:Min(600, len(content))], recentContext, question)
+		searchQueries, err := generateSearchQueries(config, searchContext, fmt.Sprintf("find information to answer: %s", question), session.ID, nil)
 
 		// Prepend the model's suggested query to the list
 		if modelSearchQuery != "" {
@@ -450,28 +470,25 @@ Now you have both the document content and search results. Answer the question c
 
 QUESTION: %s
 
-INSTRUCTIONS: Provide a complete and comprehensive answer using the document content and search results. Pay attention to pronouns and references from previous questions. Do NOT respond with "SEARCH_NEEDED" - provide the actual answer.`, enhancedContext, question)
+INSTRUCTIONS: Provide a complete and comprehensive answer using the document content and search results. Pay attention to pronouns and references from previous questions. Do NOT respond with "SEARCH_NEEDED" - provide the actual answer.
+
+%s`, enhancedContext, question, citationInstruction)
 
 				enhancedMessages := []api.Message{
 					{Role: "system", Content: systemPrompt},
 					{Role: "user", Content: enhancedPrompt},
 				}
 
-				req = &api.ChatRequest{
-					Model:    config.DefaultModel,
-					Messages: enhancedMessages,
-					Stream:   &isStreaming,
+				if stream != nil && gate != nil && !gate.suppressed {
+					// The unhelpful first answer was already shown; separate the new one
+					stream("\n\n---\n\n")
 				}
 
-				var enhancedBuilder strings.Builder
-				err = client.Chat(context.Background(), req, func(resp api.ChatResponse) error {
-					content := resp.Message.Content
-					enhancedBuilder.WriteString(content)
-					return nil
-				})
-
+				citedStream, flushCitations := citationStream(stream, searchResults)
+				finalResponse, err := provider.Chat(ctx, model, enhancedMessages, options, citedStream)
 				if err == nil {
-					finalResponse := enhancedBuilder.String()
+					flushCitations()
+					finalResponse = attachSources(finalResponse, searchResults, false, stream)
 					// Cache the enhanced response
 					cacheManager.Set(cacheKey, finalResponse, session.ID)
 					return finalResponse, nil
@@ -481,6 +498,12 @@ INSTRUCTIONS: Provide a complete and comprehensive answer using the document con
 
 		// If search failed or no results, return a helpful message
 		fallbackResponse := "I don't have enough information in the document to answer this question completely, and my search for additional information didn't yield relevant results."
+		if stream != nil {
+			if gate != nil && !gate.suppressed {
+				stream("\n\n---\n\n")
+			}
+			stream(fallbackResponse)
+		}
 		cacheManager.Set(cacheKey, fallbackResponse, session.ID)
 		return fallbackResponse, nil
 	}
@@ -585,11 +608,15 @@ func getHistoryFile() string {
 	return filepath.Join(configDir, appName, "history")
 }
 
-// StartThinkingDots shows animated thinking indicator
-func StartThinkingDots(message string) chan struct{} {
+// StartThinkingDots shows animated thinking indicator. The returned function
+// stops it and only returns once the line has been cleared; it is safe to call
+// more than once.
+func StartThinkingDots(message string) func() {
 	stop := make(chan struct{})
+	done := make(chan struct{})
 
 	go func() {
+		defer close(done)
 		dots := ""
 		ticker := time.NewTicker(400 * time.Millisecond)
 		defer ticker.Stop()
@@ -610,5 +637,11 @@ func StartThinkingDots(message string) chan struct{} {
 		}
 	}()
 
-	return stop
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+		})
+	}
 }
@@ -0,0 +1,561 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// kbCandidates is how many chunks each ranking contributes before fusion
+const kbCandidates = 50
+
+// KBEntry is one summarized source in the knowledge base
+type KBEntry struct {
+	ID        string         `json:"id"`
+	URL       string         `json:"url,omitempty"`
+	Query     string         `json:"query,omitempty"`
+	Title     string         `json:"title"`
+	Summary   string         `json:"summary"`
+	Content   string         `json:"content"`
+	AddedAt   time.Time      `json:"added_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Index     *DocumentIndex `json:"index,omitempty"`
+}
+
+// KBHit is a chunk of a knowledge base entry matching a query
+type KBHit struct {
+	Entry *KBEntry
+	Text  string
+	Score float64
+}
+
+// KnowledgeBase stores every summarized source as a JSON file with its
+// full text and, once searched, its chunk embeddings
+type KnowledgeBase struct {
+	dir    string
+	config *Config
+}
+
+func NewKnowledgeBase(config *Config) *KnowledgeBase {
+	configDir, _ := os.UserConfigDir()
+	dir := filepath.Join(configDir, appName, "kb")
+	os.MkdirAll(dir, 0755)
+
+	return &KnowledgeBase{
+		dir:    dir,
+		config: config,
+	}
+}
+
+// kbEntryID derives a stable ID from the source URL, or from the content for
+// sources without one such as stdin
+func kbEntryID(sourceURL, content string) string {
+	if sourceURL != "" {
+		return contentHash(sourceURL)[:16]
+	}
+	return contentHash(content)[:16]
+}
+
+// AddDocument records a summarized source, replacing an older entry for the
+// same URL. The embedding index is kept when the content did not change.
+func (kb *KnowledgeBase) AddDocument(sourceURL, query, title, summary, content string) (*KBEntry, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("nothing to add: empty content")
+	}
+
+	now := time.Now()
+	entry := &KBEntry{
+		ID:        kbEntryID(sourceURL, content),
+		URL:       sourceURL,
+		Query:     query,
+		Title:     title,
+		Summary:   summary,
+		Content:   content,
+		AddedAt:   now,
+		UpdatedAt: now,
+	}
+
+	if existing, err := kb.Get(entry.ID); err == nil {
+		entry.AddedAt = existing.AddedAt
+		if existing.Index.Matches(kb.config, content) {
+			entry.Index = existing.Index
+		}
+	}
+
+	if err := kb.save(entry); err != nil {
+		return nil, err
+	}
+	DebugLog(kb.config, "Added %s to the knowledge base as %s", title, entry.ID)
+	return entry, nil
+}
+
+// recordInKnowledgeBase adds a new summary to the knowledge base when it
+// is enabled. Failures only cost the entry, so they are logged.
+func recordInKnowledgeBase(config *Config, sourceURL, query, title, summary, content string) {
+	if !config.KnowledgeBase {
+		return
+	}
+	if _, err := NewKnowledgeBase(config).AddDocument(sourceURL, query, title, summary, content); err != nil {
+		DebugLog(config, "Failed to add %s to the knowledge base: %v", title, err)
+	}
+}
+
+// AddSession records the document behind an interactive session. Each
+// document of a multi-document session becomes its own entry; the last
+// one added is returned.
+func (kb *KnowledgeBase) AddSession(session *SessionData) (*KBEntry, error) {
+	if len(session.Documents) == 0 {
+		return kb.AddDocument(session.URL, session.Query, session.GetTitle(), session.InitialSummary, session.ContextContent)
+	}
+
+	var entry *KBEntry
+	for _, doc := range session.Documents {
+		var err error
+		if entry, err = kb.AddDocument(doc.URL, "", doc.Title, doc.Summary, doc.Content); err != nil {
+			return nil, err
+		}
+	}
+	return entry, nil
+}
+
+// Get loads an entry by ID
+func (kb *KnowledgeBase) Get(id string) (*KBEntry, error) {
+	data, err := os.ReadFile(filepath.Join(kb.dir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entry KBEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// List returns all entries, most recently updated first
+func (kb *KnowledgeBase) List() ([]*KBEntry, error) {
+	files, err := os.ReadDir(kb.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*KBEntry
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		entry, err := kb.Get(strings.TrimSuffix(file.Name(), ".json"))
+		if err != nil {
+			DebugLog(kb.config, "Skipping unreadable knowledge base entry %s: %v", file.Name(), err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].UpdatedAt.After(entries[j].UpdatedAt)
+	})
+	return entries, nil
+}
+
+// Remove deletes an entry given its ID or URL
+func (kb *KnowledgeBase) Remove(idOrURL string) error {
+	for _, id := range []string{idOrURL, kbEntryID(idOrURL, "")} {
+		path := filepath.Join(kb.dir, id+".json")
+		if _, err := os.Stat(path); err == nil {
+			return os.Remove(path)
+		}
+	}
+	return fmt.Errorf("no knowledge base entry for %s", idOrURL)
+}
+
+func (kb *KnowledgeBase) save(entry *KBEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(kb.dir, entry.ID+".json"), data, 0644)
+}
+
+// ensureIndexes embeds every entry that has no up-to-date index yet
+func (kb *KnowledgeBase) ensureIndexes(entries []*KBEntry, provider LLMProvider) error {
+	var stale []*KBEntry
+	for _, entry := range entries {
+		if !entry.Index.Matches(kb.config, entry.Content) {
+			stale = append(stale, entry)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "🔎 Indexing %d knowledge base documents...\n", len(stale))
+	for _, entry := range stale {
+		index, err := BuildIndex(context.Background(), kb.config, provider, entry.Content)
+		if err != nil {
+			return err
+		}
+		entry.Index = index
+		if err := kb.save(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Retrieve finds the chunks most relevant to the query by fusing a semantic
+// ranking (embeddings) with a keyword ranking. Without a working embedding
+// model it falls back to keywords only.
+func (kb *KnowledgeBase) Retrieve(query string, limit int) ([]KBHit, error) {
+	entries, err := kb.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("the knowledge base is empty; add sources with '%s kb add <url>'", appName)
+	}
+
+	provider, err := NewLLMProvider(kb.config)
+	if err != nil {
+		return nil, err
+	}
+
+	var queryEmbedding []float32
+	if err := kb.ensureIndexes(entries, provider); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Semantic search unavailable, using keywords only: %v\n", err)
+	} else if embeddings, err := provider.Embed(context.Background(), kb.config.Retrieval.EmbeddingModel, []string{query}); err != nil || len(embeddings) == 0 {
+		fmt.Fprintf(os.Stderr, "⚠️  Semantic search unavailable, using keywords only: %v\n", err)
+	} else {
+		queryEmbedding = embeddings[0]
+	}
+
+	// Collect candidate chunks, chunking unindexed entries on the fly
+	var chunks []KBHit
+	var embeddings [][]float32
+	for _, entry := range entries {
+		if entry.Index != nil && queryEmbedding != nil {
+			for _, chunk := range entry.Index.Chunks {
+				chunks = append(chunks, KBHit{Entry: entry, Text: chunk.Text})
+				embeddings = append(embeddings, chunk.Embedding)
+			}
+			continue
+		}
+		chunkTokens := Max(kb.config.Retrieval.ChunkTokens, 64)
+		for _, text := range ChunkText(entry.Content, chunkTokens, chunkTokens/8) {
+			chunks = append(chunks, KBHit{Entry: entry, Text: text})
+			embeddings = append(embeddings, nil)
+		}
+	}
+
+	var rankings [][]int
+	if queryEmbedding != nil {
+		rankings = append(rankings, rankChunks(len(chunks), func(i int) float64 {
+			return cosineSimilarity(queryEmbedding, embeddings[i])
+		}))
+	}
+	terms := focusTerms(query)
+	rankings = append(rankings, rankChunks(len(chunks), func(i int) float64 {
+		return keywordScore(chunks[i], terms)
+	}))
+
+	// Reciprocal rank fusion, as for search engines
+	scores := make(map[int]float64)
+	for _, ranking := range rankings {
+		for rank, index := range ranking {
+			scores[index] += 1.0 / float64(rrfK+rank+1)
+		}
+	}
+
+	var indices []int
+	for index := range scores {
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		if scores[indices[i]] != scores[indices[j]] {
+			return scores[indices[i]] > scores[indices[j]]
+		}
+		return indices[i] < indices[j]
+	})
+
+	var hits []KBHit
+	for _, index := range indices {
+		hit := chunks[index]
+		hit.Score = scores[index]
+		hits = append(hits, hit)
+	}
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+// rankChunks returns the indices of the best-scoring chunks, dropping those
+// that score zero
+func rankChunks(count int, score func(int) float64) []int {
+	type scoredIndex struct {
+		index int
+		score float64
+	}
+
+	var scored []scoredIndex
+	for i := 0; i < count; i++ {
+		if s := score(i); s > 0 {
+			scored = append(scored, scoredIndex{index: i, score: s})
+		}
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+
+	ranking := make([]int, 0, Min(len(scored), kbCandidates))
+	for i := 0; i < len(scored) && i < kbCandidates; i++ {
+		ranking = append(ranking, scored[i].index)
+	}
+	return ranking
+}
+
+// keywordScore counts query terms in a chunk, with title matches counting extra
+func keywordScore(hit KBHit, terms []string) float64 {
+	text := strings.ToLower(hit.Text)
+	title := strings.ToLower(hit.Entry.Title)
+
+	score := 0.0
+	for _, term := range terms {
+		if count := strings.Count(text, term); count > 0 {
+			score += 1 + float64(count)/10
+		}
+		if strings.Contains(title, term) {
+			score += 0.5
+		}
+	}
+	return score
+}
+
+// Search returns the best matching chunk of each of the top entries
+func (kb *KnowledgeBase) Search(query string, limit int) ([]KBHit, error) {
+	hits, err := kb.Retrieve(query, kbCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var results []KBHit
+	for _, hit := range hits {
+		if seen[hit.Entry.ID] {
+			continue
+		}
+		seen[hit.Entry.ID] = true
+		results = append(results, hit)
+		if len(results) >= limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// Ask answers a question from the most relevant chunks across all entries,
+// citing the entries they came from
+func (kb *KnowledgeBase) Ask(question string, useMarkdown bool, stream StreamFunc) (string, error) {
+	hits, err := kb.Retrieve(question, Max(kb.config.Retrieval.TopK, 1)*2)
+	if err != nil {
+		return "", err
+	}
+	if len(hits) == 0 {
+		return "", fmt.Errorf("nothing in the knowledge base matches the question")
+	}
+
+	// Number the entries in order of relevance, like search results
+	var sources []SearchResult
+	sourceNumbers := make(map[string]int)
+	var excerpts strings.Builder
+	for _, hit := range hits {
+		number, ok := sourceNumbers[hit.Entry.ID]
+		if !ok {
+			sources = append(sources, SearchResult{
+				Title:   hit.Entry.Title,
+				URL:     hit.Entry.URL,
+				Snippet: TruncateString(hit.Entry.Summary, 200),
+				Source:  "Knowledge base",
+			})
+			number = len(sources)
+			sourceNumbers[hit.Entry.ID] = number
+		}
+		excerpts.WriteString(fmt.Sprintf("\n[%d] %s\n%s\n", number, hit.Entry.Title, hit.Text))
+	}
+
+	systemPrompt := kb.config.SystemPrompts.QnA
+	if useMarkdown {
+		systemPrompt += "\n\nFormat your answer as markdown."
+	}
+	userPrompt := fmt.Sprintf(`EXCERPTS FROM PREVIOUSLY READ DOCUMENTS:
+%s
+
+QUESTION: %s
+
+Answer the question using ONLY the excerpts above. If they do not contain the answer, say so.
+
+CITATIONS: Cite the documents you use inline with their bracketed numbers, e.g. [1] or [2][3]. Only cite numbers that exist above. Do not add a sources or references list yourself.`, excerpts.String(), question)
+
+	citedStream, flushCitations := citationStream(stream, sources)
+	answer, err := callLLMStream(kb.config, TaskQnA, systemPrompt, userPrompt, citedStream)
+	if err != nil {
+		return "", err
+	}
+
+	flushCitations()
+	return attachSources(answer, sources, useMarkdown, stream), nil
+}
+
+// runKBCommand handles "hvsum kb <add|search|ask|ls|rm> ..." and returns the exit code
+func runKBCommand(args []string, config *Config, length string, useMarkdown bool) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s kb <add|search|ask|ls|rm> [arguments]\n", appName)
+		return 1
+	}
+
+	kb := NewKnowledgeBase(config)
+	action, rest := args[0], args[1:]
+
+	switch action {
+	case "add":
+		if len(rest) == 0 {
+			fmt.Fprintf(os.Stderr, "Usage: %s kb add <URL, file or session>...\n", appName)
+			return 1
+		}
+		failures := 0
+		for _, input := range rest {
+			entry, err := kbAddInput(kb, input, config, length, useMarkdown)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %s: %v\n", input, err)
+				failures++
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "✅ Added %s (%s)\n", entry.Title, entry.ID)
+		}
+		if failures > 0 {
+			return 1
+		}
+		return 0
+
+	case "search":
+		query := strings.Join(rest, " ")
+		if query == "" {
+			fmt.Fprintf(os.Stderr, "Usage: %s kb search \"<query>\"\n", appName)
+			return 1
+		}
+		hits, err := kb.Search(query, 10)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if len(hits) == 0 {
+			fmt.Println("No matching documents.")
+			return 0
+		}
+		for i, hit := range hits {
+			fmt.Printf("%d. %s\n", i+1, hit.Entry.Title)
+			if hit.Entry.URL != "" {
+				fmt.Printf("   %s\n", hit.Entry.URL)
+			}
+			fmt.Printf("   %s\n\n", TruncateString(strings.Join(strings.Fields(hit.Text), " "), 240))
+		}
+		return 0
+
+	case "ask":
+		question := strings.Join(rest, " ")
+		if question == "" {
+			fmt.Fprintf(os.Stderr, "Usage: %s kb ask \"<question>\"\n", appName)
+			return 1
+		}
+
+		var renderer *StreamRenderer
+		var stream StreamFunc
+		if !config.DisableStreaming {
+			renderer = NewStreamRenderer(useMarkdown)
+			stream = renderer.Write
+		}
+
+		answer, err := kb.Ask(question, useMarkdown, stream)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if renderer == nil || !renderer.Finish() {
+			RenderToConsole(answer, useMarkdown)
+		}
+		return 0
+
+	case "ls", "list":
+		entries, err := kb.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if len(entries) == 0 {
+			fmt.Println("The knowledge base is empty.")
+			return 0
+		}
+		fmt.Printf("📚 Knowledge base (%d documents):\n", len(entries))
+		for _, entry := range entries {
+			location := entry.URL
+			if location == "" {
+				location = entry.Query
+			}
+			fmt.Printf("  %s  %s  %s  %s\n", entry.ID, entry.UpdatedAt.Format("2006-01-02"), entry.Title, location)
+		}
+		return 0
+
+	case "rm", "remove":
+		if len(rest) == 0 {
+			fmt.Fprintf(os.Stderr, "Usage: %s kb rm <id or URL>...\n", appName)
+			return 1
+		}
+		for _, target := range rest {
+			if err := kb.Remove(target); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+		}
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown kb command '%s' (use add, search, ask, ls or rm)\n", action)
+		return 1
+	}
+}
+
+// kbAddInput summarizes a URL or file, or takes a saved session, and adds it
+func kbAddInput(kb *KnowledgeBase, input string, config *Config, length string, useMarkdown bool) (*KBEntry, error) {
+	source := DetectSource(input, config)
+	if source == nil {
+		sessionManager := NewSessionManager(config)
+		if !sessionManager.SessionExists(input) {
+			return nil, fmt.Errorf("not a URL, file or saved session")
+		}
+		session, err := sessionManager.LoadSession(input)
+		if err != nil {
+			return nil, err
+		}
+		return kb.AddSession(session)
+	}
+
+	// ProcessSource only records fresh summaries, so the document is added
+	// here, which also covers cached summaries and returns the entry
+	processConfig := *config
+	processConfig.KnowledgeBase = false
+	summary, content, title, err := ProcessSource(source, &processConfig, length, useMarkdown, false, "", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceURL := source.Location()
+	if sourceURL == "-" {
+		sourceURL = ""
+	}
+	return kb.AddDocument(sourceURL, "", title, summary, content)
+}
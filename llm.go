@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
+)
+
+// StreamFunc receives response tokens as they are generated
+type StreamFunc func(token string)
+
+// LLMProvider abstracts the model backend used for generation and chat.
+// Passing a nil StreamFunc disables streaming; the full response is always returned.
+type LLMProvider interface {
+	Generate(ctx context.Context, model, systemPrompt, userPrompt string, options map[string]interface{}, stream StreamFunc) (string, error)
+	Chat(ctx context.Context, model string, messages []api.Message, options map[string]interface{}, stream StreamFunc) (string, error)
+	Embed(ctx context.Context, model string, inputs []string) ([][]float32, error)
+	Name() string
+}
+
+// NewLLMProvider creates the provider selected in the configuration
+func NewLLMProvider(config *Config) (LLMProvider, error) {
+	switch strings.ToLower(config.LLM.Provider) {
+	case "", "ollama":
+		// Without a base URL the client connects to OLLAMA_HOST
+		endpoint := config.LLM.BaseURL
+		if endpoint == "" {
+			endpoint = envconfig.Host().String()
+		}
+		if config.Offline && !isLoopbackURL(endpoint) {
+			return nil, fmt.Errorf("LLM endpoint %s is not local: %w", endpoint, errOffline)
+		}
+		return NewOllamaProvider(config.LLM.BaseURL)
+	case "openai":
+		if config.Offline && config.LLM.BaseURL != "" && !isLoopbackURL(config.LLM.BaseURL) {
+			return nil, fmt.Errorf("LLM endpoint %s is not local: %w", config.LLM.BaseURL, errOffline)
+		}
+		return NewOpenAIProvider(config.LLM.BaseURL, config.LLM.APIKey), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s", config.LLM.Provider)
+	}
+}
+
+// isLoopbackURL reports whether a URL points at this machine. The
+// unspecified address counts, as OLLAMA_HOST=0.0.0.0 is a common setting.
+func isLoopbackURL(rawURL string) bool {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := parsedURL.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
+
+// OllamaProvider implements LLMProvider using the Ollama API
+type OllamaProvider struct {
+	client *api.Client
+}
+
+// NewOllamaProvider creates an Ollama provider, using OLLAMA_HOST when no base URL is given
+func NewOllamaProvider(baseURL string) (*OllamaProvider, error) {
+	if baseURL == "" {
+		client, err := api.ClientFromEnvironment()
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to Ollama: %v", err)
+		}
+		return &OllamaProvider{client: client}, nil
+	}
+
+	parsedURL, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ollama URL: %w", err)
+	}
+	return &OllamaProvider{client: api.NewClient(parsedURL, http.DefaultClient)}, nil
+}
+
+func (o *OllamaProvider) Name() string {
+	return "Ollama"
+}
+
+// Generate runs a single prompt completion
+func (o *OllamaProvider) Generate(ctx context.Context, model, systemPrompt, userPrompt string, options map[string]interface{}, stream StreamFunc) (string, error) {
+	isStreaming := stream != nil
+	req := &api.GenerateRequest{
+		Model:   model,
+		System:  systemPrompt,
+		Prompt:  userPrompt,
+		Stream:  &isStreaming,
+		Options: options,
+	}
+
+	var responseBuilder strings.Builder
+	err := o.client.Generate(ctx, req, func(resp api.GenerateResponse) error {
+		responseBuilder.WriteString(resp.Response)
+		if stream != nil && resp.Response != "" {
+			stream(resp.Response)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return responseBuilder.String(), nil
+}
+
+// Chat runs a chat completion over the given messages
+func (o *OllamaProvider) Chat(ctx context.Context, model string, messages []api.Message, options map[string]interface{}, stream StreamFunc) (string, error) {
+	isStreaming := stream != nil
+	req := &api.ChatRequest{
+		Model:    model,
+		Messages: messages,
+		Stream:   &isStreaming,
+		Options:  options,
+	}
+
+	var responseBuilder strings.Builder
+	err := o.client.Chat(ctx, req, func(resp api.ChatResponse) error {
+		responseBuilder.WriteString(resp.Message.Content)
+		if stream != nil && resp.Message.Content != "" {
+			stream(resp.Message.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return responseBuilder.String(), nil
+}
+
+// Embed returns one embedding vector per input
+func (o *OllamaProvider) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	resp, err := o.client.Embed(ctx, &api.EmbedRequest{
+		Model: model,
+		Input: inputs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(resp.Embeddings))
+	}
+
+	return resp.Embeddings, nil
+}
+
+// OpenAIProvider implements LLMProvider for servers speaking the OpenAI
+// /v1/chat/completions protocol (llama.cpp server, vLLM, LM Studio, ...)
+type OpenAIProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewOpenAIProvider creates an OpenAI-compatible provider
+func NewOpenAIProvider(baseURL, apiKey string) *OpenAIProvider {
+	if baseURL == "" {
+		baseURL = "http://localhost:8080/v1"
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+
+	return &OpenAIProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client: &http.Client{
+			Timeout: 10 * time.Minute,
+		},
+	}
+}
+
+func (o *OpenAIProvider) Name() string {
+	return "OpenAI-compatible"
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIChatRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Stream      bool            `json:"stream"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	TopP        *float64        `json:"top_p,omitempty"`
+	TopK        *int            `json:"top_k,omitempty"` // Not in the OpenAI API, but llama.cpp and vLLM accept it
+	Seed        *int            `json:"seed,omitempty"`
+	Stop        []string        `json:"stop,omitempty"`
+	MaxTokens   *int            `json:"max_tokens,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta openAIMessage `json:"delta"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Generate runs a single prompt completion as a two-message chat
+func (o *OpenAIProvider) Generate(ctx context.Context, model, systemPrompt, userPrompt string, options map[string]interface{}, stream StreamFunc) (string, error) {
+	var messages []api.Message
+	if systemPrompt != "" {
+		messages = append(messages, api.Message{Role: "system", Content: systemPrompt})
+	}
+	messages = append(messages, api.Message{Role: "user", Content: userPrompt})
+
+	return o.Chat(ctx, model, messages, options, stream)
+}
+
+// Chat posts the messages to /chat/completions
+func (o *OpenAIProvider) Chat(ctx context.Context, model string, messages []api.Message, options map[string]interface{}, stream StreamFunc) (string, error) {
+	reqBody := openAIChatRequest{Model: model, Stream: stream != nil}
+	for _, msg := range messages {
+		reqBody.Messages = append(reqBody.Messages, openAIMessage{Role: msg.Role, Content: msg.Content})
+	}
+	applyOpenAIOptions(&reqBody, options)
+
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("%s request failed with status %d: %s", o.Name(), resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if stream != nil {
+		return o.readStream(resp.Body, stream)
+	}
+
+	var chatResp openAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if chatResp.Error != nil {
+		return "", fmt.Errorf("%s error: %s", o.Name(), chatResp.Error.Message)
+	}
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("%s returned no choices", o.Name())
+	}
+
+	return chatResp.Choices[0].Message.Content, nil
+}
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Embed posts the inputs to /embeddings
+func (o *OpenAIProvider) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	payload, err := json.Marshal(openAIEmbeddingRequest{Model: model, Input: inputs})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/embeddings", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s embedding request failed with status %d: %s", o.Name(), resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var embedResp openAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings: %w", err)
+	}
+	if embedResp.Error != nil {
+		return nil, fmt.Errorf("%s error: %s", o.Name(), embedResp.Error.Message)
+	}
+
+	embeddings := make([][]float32, len(inputs))
+	for _, item := range embedResp.Data {
+		if item.Index >= 0 && item.Index < len(embeddings) {
+			embeddings[item.Index] = item.Embedding
+		}
+	}
+	for i, embedding := range embeddings {
+		if embedding == nil {
+			return nil, fmt.Errorf("%s returned no embedding for input %d", o.Name(), i)
+		}
+	}
+
+	return embeddings, nil
+}
+
+// readStream consumes a server-sent event stream of chat completion chunks
+func (o *OpenAIProvider) readStream(body io.Reader, stream StreamFunc) (string, error) {
+	var responseBuilder strings.Builder
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return "", fmt.Errorf("%s error: %s", o.Name(), chunk.Error.Message)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		token := chunk.Choices[0].Delta.Content
+		responseBuilder.WriteString(token)
+		stream(token)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return responseBuilder.String(), nil
+}
+
+// applyOpenAIOptions maps Ollama-style option names onto the OpenAI request fields
+func applyOpenAIOptions(req *openAIChatRequest, options map[string]interface{}) {
+	for key, value := range options {
+		switch key {
+		case "temperature":
+			if f, ok := toFloat(value); ok {
+				req.Temperature = &f
+			}
+		case "top_p":
+			if f, ok := toFloat(value); ok {
+				req.TopP = &f
+			}
+		case "top_k":
+			if f, ok := toFloat(value); ok {
+				topK := int(f)
+				req.TopK = &topK
+			}
+		case "seed":
+			if f, ok := toFloat(value); ok {
+				seed := int(f)
+				req.Seed = &seed
+			}
+		case "num_predict":
+			if f, ok := toFloat(value); ok && f > 0 {
+				maxTokens := int(f)
+				req.MaxTokens = &maxTokens
+			}
+		case "stop":
+			switch stop := value.(type) {
+			case []string:
+				req.Stop = stop
+			case []interface{}:
+				// Values decoded from the JSON config
+				for _, item := range stop {
+					if text, ok := item.(string); ok {
+						req.Stop = append(req.Stop, text)
+					}
+				}
+			case string:
+				req.Stop = []string{stop}
+			}
+		}
+	}
+}
+
+// toFloat converts numeric option values of any JSON-compatible type to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
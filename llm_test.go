@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestOpenAIChat(t *testing.T) {
+	var got openAIChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("request = %s %s, want POST /v1/chat/completions", r.Method, r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "Hello there"}}]}`)
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(server.URL+"/v1/", "secret")
+	messages := []api.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "hi"},
+	}
+	reply, err := provider.Chat(context.Background(), "llama", messages, map[string]interface{}{"temperature": 0.2}, nil)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if reply != "Hello there" {
+		t.Errorf("reply = %q", reply)
+	}
+
+	if got.Model != "llama" || got.Stream || len(got.Messages) != 2 || got.Messages[1].Content != "hi" {
+		t.Errorf("unexpected request: %+v", got)
+	}
+	if got.Temperature == nil || *got.Temperature != 0.2 {
+		t.Errorf("temperature = %v, want 0.2", got.Temperature)
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"error status", http.StatusBadRequest, `{"error": {"message": "model not found"}}`, `status 400: {"error": {"message": "model not found"}}`},
+		{"error body", http.StatusOK, `{"error": {"message": "context too long"}}`, "OpenAI-compatible error: context too long"},
+		{"no choices", http.StatusOK, `{"choices": []}`, "no choices"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			_, err := NewOpenAIProvider(server.URL, "").Generate(context.Background(), "m", "", "hi", nil, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openAIChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Error("stream = false, want true")
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		// One event is split across two writes
+		for _, part := range []string{
+			`data: {"choices": [{"delta": {"role": "assistant"}}]}` + "\n\n",
+			`data: {"choices": [{"delta": {"content": "Hel`,
+			`lo"}}]}` + "\n\n",
+			": keep-alive comment\n\n",
+			`data: {"choices": [{"delta": {"content": " world"}}]}` + "\n\n",
+			"data: [DONE]\n\n",
+			`data: {"choices": [{"delta": {"content": " ignored"}}]}` + "\n\n",
+		} {
+			fmt.Fprint(w, part)
+			flusher.Flush()
+		}
+	}))
+	defer server.Close()
+
+	var tokens []string
+	reply, err := NewOpenAIProvider(server.URL, "").Chat(context.Background(), "m", []api.Message{{Role: "user", Content: "hi"}}, nil, func(token string) {
+		tokens = append(tokens, token)
+	})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if reply != "Hello world" {
+		t.Errorf("reply = %q, want %q", reply, "Hello world")
+	}
+	if !reflect.DeepEqual(tokens, []string{"Hello", " world"}) {
+		t.Errorf("tokens = %q", tokens)
+	}
+}
+
+func TestReadStream(t *testing.T) {
+	provider := NewOpenAIProvider("http://localhost", "")
+
+	body := "data:{\"choices\":[{\"delta\":{\"content\":\"a\"}}]}\r\n\r\n" +
+		"event: ping\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"b\"}}]}\n\n" +
+		"data: [DONE]\n"
+	// Reading one byte at a time splits every line across reads
+	reply, err := provider.readStream(iotest.OneByteReader(strings.NewReader(body)), func(string) {})
+	if err != nil || reply != "ab" {
+		t.Errorf("reply = %q, err = %v; want \"ab\"", reply, err)
+	}
+
+	// A stream that ends without [DONE] keeps what was received
+	reply, err = provider.readStream(strings.NewReader("data: {\"choices\":[{\"delta\":{\"content\":\"partial\"}}]}\n"), func(string) {})
+	if err != nil || reply != "partial" {
+		t.Errorf("reply = %q, err = %v; want \"partial\"", reply, err)
+	}
+
+	_, err = provider.readStream(strings.NewReader("data: {\"error\":{\"message\":\"overloaded\"}}\n"), func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "overloaded") {
+		t.Errorf("error = %v, want the stream error message", err)
+	}
+
+	_, err = provider.readStream(strings.NewReader("data: {not json\n"), func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "failed to decode stream chunk") {
+		t.Errorf("error = %v, want a decode error", err)
+	}
+}
+
+func TestOpenAIEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Errorf("path = %s, want /embeddings", r.URL.Path)
+		}
+		var req openAIEmbeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "embed" || len(req.Input) != 2 {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		// Results may come back out of order and are placed by index
+		fmt.Fprint(w, `{"data": [{"index": 1, "embedding": [0.3, 0.4]}, {"index": 0, "embedding": [0.1, 0.2]}]}`)
+	}))
+	defer server.Close()
+
+	embeddings, err := NewOpenAIProvider(server.URL, "").Embed(context.Background(), "embed", []string{"first", "second"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	want := [][]float32{{0.1, 0.2}, {0.3, 0.4}}
+	if !reflect.DeepEqual(embeddings, want) {
+		t.Errorf("embeddings = %v, want %v", embeddings, want)
+	}
+}
+
+func TestOpenAIEmbedErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"error status", http.StatusNotFound, "no such model", "status 404: no such model"},
+		{"error body", http.StatusOK, `{"error": {"message": "bad input"}}`, "bad input"},
+		{"missing embedding", http.StatusOK, `{"data": [{"index": 0, "embedding": [1]}]}`, "no embedding for input 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			_, err := NewOpenAIProvider(server.URL, "").Embed(context.Background(), "embed", []string{"a", "b"})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOpenAIOptions(t *testing.T) {
+	var req openAIChatRequest
+	applyOpenAIOptions(&req, map[string]interface{}{
+		"temperature": 0.7,
+		"top_p":       float32(0.5),
+		"top_k":       40,
+		"seed":        int64(42),
+		"num_predict": 256.0,
+		"stop":        []interface{}{"###", 3, "END"},
+		"num_ctx":     8192, // Ollama only, dropped
+	})
+
+	if req.Temperature == nil || *req.Temperature != 0.7 {
+		t.Errorf("temperature = %v", req.Temperature)
+	}
+	if req.TopP == nil || *req.TopP != 0.5 {
+		t.Errorf("top_p = %v", req.TopP)
+	}
+	if req.TopK == nil || *req.TopK != 40 {
+		t.Errorf("top_k = %v", req.TopK)
+	}
+	if req.Seed == nil || *req.Seed != 42 {
+		t.Errorf("seed = %v", req.Seed)
+	}
+	if req.MaxTokens == nil || *req.MaxTokens != 256 {
+		t.Errorf("max_tokens = %v, want num_predict 256", req.MaxTokens)
+	}
+	if !reflect.DeepEqual(req.Stop, []string{"###", "END"}) {
+		t.Errorf("stop = %q", req.Stop)
+	}
+
+	// num_predict <= 0 means unlimited in Ollama and is left out
+	req = openAIChatRequest{}
+	applyOpenAIOptions(&req, map[string]interface{}{"num_predict": -1, "stop": "\n\n", "temperature": "hot"})
+	if req.MaxTokens != nil {
+		t.Errorf("max_tokens = %d, want unset", *req.MaxTokens)
+	}
+	if !reflect.DeepEqual(req.Stop, []string{"\n\n"}) {
+		t.Errorf("stop = %q", req.Stop)
+	}
+	if req.Temperature != nil {
+		t.Errorf("temperature = %v, want unset for a non-numeric value", *req.Temperature)
+	}
+
+	data, _ := json.Marshal(req)
+	if strings.Contains(string(data), "max_tokens") || strings.Contains(string(data), "top_p") {
+		t.Errorf("unset options are serialized: %s", data)
+	}
+}
+
+func TestNewLLMProviderOffline(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   string
+		baseURL    string
+		ollamaHost string
+		local      bool
+	}{
+		{"ollama default", "ollama", "", "", true},
+		{"ollama local url", "", "http://localhost:11434", "", true},
+		{"ollama remote url", "ollama", "http://gpu.example:11434", "", false},
+		{"ollama remote host", "ollama", "", "gpu.example", false},
+		{"ollama all interfaces", "ollama", "", "0.0.0.0:11434", true},
+		{"openai default", "openai", "", "", true},
+		{"openai remote", "openai", "https://api.example/v1", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OLLAMA_HOST", tt.ollamaHost)
+			config := &Config{Offline: true}
+			config.LLM.Provider = tt.provider
+			config.LLM.BaseURL = tt.baseURL
+
+			_, err := NewLLMProvider(config)
+			if tt.local && err != nil {
+				t.Errorf("error = %v, want a local provider", err)
+			}
+			if !tt.local && !errors.Is(err, errOffline) {
+				t.Errorf("error = %v, want errOffline", err)
+			}
+		})
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/ollama/ollama/api"
 	"github.com/spf13/pflag"
 )
 
@@ -16,27 +15,43 @@ const version = "0.2.0-beta"
 func main() {
 	// Define flags
 	var (
-		showVersion     bool
-		enableSearch    bool
-		showHelp        bool
-		useMarkdown     bool
-		disablePager    bool
-		disableQnA      bool
-		generateOutline bool
-		copyToClipboard bool
-		cleanCache      bool
-		listSessions    bool
-		cleanSessions   bool
-		debugMode       bool
-		disableCache    bool
-		length          string
-		sessionName     string
-		saveToFile      string
-		configPath      string
+		showVersion      bool
+		enableSearch     bool
+		showHelp         bool
+		useMarkdown      bool
+		disablePager     bool
+		disableQnA       bool
+		generateOutline  bool
+		copyToClipboard  bool
+		cleanCache       bool
+		listSessions     bool
+		cleanSessions    bool
+		debugMode        bool
+		disableCache     bool
+		disableStream    bool
+		deepSearch       bool
+		offline          bool
+		length           string
+		sessionName      string
+		saveToFile       string
+		configPath       string
+		profileName      string
+		outputFormat     string
+		batchFile        string
+		batchDir         string
+		serveAddr        string
+		cacheKind        string
+		olderThan        string
+		watchInterval    string
+		webhookURL       string
+		workers          int
+		llmConcurrency   int
+		fetchConcurrency int
 	)
 
 	pflag.BoolVarP(&showVersion, "version", "v", false, "Show application version")
 	pflag.BoolVarP(&enableSearch, "search", "s", false, "Enhance summary with web search")
+	pflag.BoolVar(&deepSearch, "deep", false, "Read the top search result pages, not just snippets (implies --search)")
 	pflag.BoolVarP(&showHelp, "help", "h", false, "Show help message")
 	pflag.BoolVarP(&useMarkdown, "markdown", "m", false, "Render output as markdown")
 	pflag.BoolVar(&disablePager, "no-pager", false, "Disable pager for output")
@@ -48,22 +63,51 @@ func main() {
 	pflag.BoolVar(&cleanSessions, "clean-sessions", false, "Clean all saved sessions")
 	pflag.BoolVar(&debugMode, "debug", false, "Enable debug logging")
 	pflag.BoolVar(&disableCache, "no-cache", false, "Disable caching for this session")
-	pflag.StringVarP(&length, "length", "l", "detailed", "Set summary length (short, medium, long, detailed)")
+	pflag.BoolVar(&offline, "offline", false, "Work from cached pages and results only, with no network access except the LLM")
+	pflag.BoolVar(&disableStream, "no-stream", false, "Wait for the full response instead of streaming it")
+	pflag.StringVarP(&length, "length", "l", "", "Set summary length: short, medium, long, detailed (default from config)")
 	pflag.StringVar(&sessionName, "session", "", "Resume a saved session by name")
 	pflag.StringVarP(&saveToFile, "write", "w", "", "Save the summary to a file (.md or .txt)")
 	pflag.StringVar(&configPath, "config", "", "Path to a custom config file")
+	pflag.StringVar(&profileName, "profile", "", "Use a named profile from the config file")
+	pflag.StringVar(&outputFormat, "format", "text", "Output format (text, json)")
+	pflag.StringVar(&batchFile, "batch", "", "Summarize newline-separated URLs from a file (- for stdin)")
+	pflag.StringVar(&batchDir, "batch-dir", "", "Write one file per batch entry into this directory")
+	pflag.StringVar(&serveAddr, "addr", defaultServeAddr, "Listen address for serve mode")
+	pflag.StringVar(&cacheKind, "kind", "", "Only list cache entries of this kind (url, search, qa, ...)")
+	pflag.StringVar(&olderThan, "older-than", "", "Remove cache entries older than this age, e.g. 7d")
+	pflag.StringVar(&watchInterval, "interval", "1h", "How often watch mode checks the pages, e.g. 30m or 1d")
+	pflag.StringVar(&webhookURL, "webhook", "", "POST watch mode changes as JSON to this URL")
+	pflag.IntVar(&workers, "workers", 0, "Number of batch workers (default from config)")
+	pflag.IntVar(&llmConcurrency, "llm-concurrency", 0, "Maximum concurrent model calls in batch mode (default from config)")
+	pflag.IntVar(&fetchConcurrency, "fetch-concurrency", 0, "Maximum concurrent page fetches in batch mode (default from config)")
 
 	pflag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <URL or search query>\n\n", appName)
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <URL, file, - or search query>\n\n", appName)
 		fmt.Fprintf(os.Stderr, "A powerful CLI tool to summarize web pages and search queries.\n\n")
 		fmt.Fprintf(os.Stderr, "Examples:\n")
 		fmt.Fprintf(os.Stderr, "  %s https://example.com                     # Summarize URL only\n", appName)
 		fmt.Fprintf(os.Stderr, "  %s -s https://example.com                 # Summarize URL + web search\n", appName)
 		fmt.Fprintf(os.Stderr, "  %s -m -l short https://wikipedia.org/...  # Markdown, short summary\n", appName)
 		fmt.Fprintf(os.Stderr, "  %s -s 'latest AI research'                # Search query with enhancement\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s --deep 'rust async runtimes'           # Read the result pages too\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s notes.md                               # Summarize a local file\n", appName)
+		fmt.Fprintf(os.Stderr, "  curl -s https://example.com | %s -        # Summarize piped input\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s --profile quick https://example.com    # Use a config profile\n", appName)
 		fmt.Fprintf(os.Stderr, "  %s --session mysession                    # Resume saved session\n", appName)
 		fmt.Fprintf(os.Stderr, "  %s --list-sessions                        # List saved sessions\n", appName)
-		fmt.Fprintf(os.Stderr, "  %s --no-cache https://example.com         # Disable caching\n\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s --no-cache https://example.com         # Disable caching\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s --offline https://example.com          # Use the copy fetched earlier\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s --batch urls.txt -w report.md          # Summarize a list of URLs\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s --format json https://example.com     # Machine-readable output\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s cache stats                           # Show cache usage and hit rates\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s cache rm --older-than 7d               # Remove old cache entries\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s kb search \"vector databases\"            # Search everything summarized so far\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s kb ask \"which runtime is fastest?\"     # Answer from the knowledge base\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s compare https://a.com https://b.com    # Compare and synthesize sources\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s diff policy-v1.pdf policy-v2.pdf        # Summarize what changed\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s watch https://example.com/status       # Summarize page changes hourly\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s serve --addr 127.0.0.1:8787            # Run the HTTP API server\n\n", appName)
 		fmt.Fprintf(os.Stderr, "Flags:\n")
 		pflag.PrintDefaults()
 	}
@@ -80,12 +124,20 @@ func main() {
 		return
 	}
 
-	config, err := LoadConfig()
+	// Layers: defaults < user config < --config file < HVSUM_* env < flags
+	config, err := LoadConfig(configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 		os.Exit(1)
 	}
 
+	if profileName != "" {
+		if err := config.ApplyProfile(profileName); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Enable debug mode if requested
 	if debugMode {
 		config.DebugMode = true
@@ -96,6 +148,36 @@ func main() {
 		config.CacheEnabled = false
 	}
 
+	if offline {
+		config.Offline = true
+	}
+
+	// Disable streaming if requested
+	if disableStream {
+		config.DisableStreaming = true
+	}
+
+	if disablePager {
+		config.DisablePager = true
+	}
+	if disableQnA {
+		config.DisableQnA = true
+	}
+
+	// Only an explicit --length overrides the configured default
+	if pflag.CommandLine.Changed("length") {
+		config.DefaultLength = length
+	} else {
+		length = config.DefaultLength
+	}
+	if !pflag.CommandLine.Changed("search") {
+		enableSearch = config.EnableSearch
+	}
+	if deepSearch {
+		config.Search.DeepSearch = true
+		enableSearch = true
+	}
+
 	sessionManager := NewSessionManager(config)
 
 	// Handle standalone flags that don't require an input arg
@@ -104,6 +186,63 @@ func main() {
 	}
 
 	args := pflag.Args()
+	output := OutputOptions{UseMarkdown: useMarkdown, SaveToFile: saveToFile, Copy: copyToClipboard, Label: "Summary"}
+
+	// Handle cache management commands
+	if len(args) > 0 && args[0] == "cache" {
+		os.Exit(runCacheCommand(args[1:], config, CacheCommandOptions{Kind: cacheKind, OlderThan: olderThan}))
+	}
+
+	// Handle knowledge base commands
+	if len(args) > 0 && args[0] == "kb" {
+		os.Exit(runKBCommand(args[1:], config, length, useMarkdown))
+	}
+
+	// Handle multi-source comparison
+	if len(args) > 0 && args[0] == "compare" {
+		os.Exit(runCompareCommand(args[1:], config, length, enableSearch, output))
+	}
+
+	// Handle document diffs
+	if len(args) > 0 && args[0] == "diff" {
+		os.Exit(runDiffCommand(args[1:], config, length, enableSearch, output))
+	}
+
+	// Handle watch mode
+	if len(args) > 0 && args[0] == "watch" {
+		interval, err := parseAge(watchInterval)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(runWatchCommand(args[1:], config, WatchOptions{
+			Interval:    interval,
+			UseMarkdown: useMarkdown,
+			OutputFile:  saveToFile,
+			Webhook:     webhookURL,
+		}))
+	}
+
+	// Handle API server mode
+	if len(args) > 0 && args[0] == "serve" {
+		if err := RunServer(serveAddr, config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Handle batch mode
+	if batchFile != "" {
+		os.Exit(runBatchMode(batchFile, config, BatchOptions{
+			Length:       length,
+			UseMarkdown:  useMarkdown,
+			EnableSearch: enableSearch,
+			Workers:      firstPositive(workers, config.BatchWorkers),
+			OutputFile:   saveToFile,
+			OutputDir:    batchDir,
+		}, firstPositive(llmConcurrency, config.LLMConcurrency), firstPositive(fetchConcurrency, config.FetchConcurrency)))
+	}
 
 	// Handle session resumption
 	if sessionName != "" {
@@ -119,63 +258,68 @@ func main() {
 
 	input := strings.Join(args, " ")
 
+	// JSON output is meant for scripts: no streaming, pager or interactive session
+	jsonOutput := outputFormat == "json"
+	if !jsonOutput && outputFormat != "text" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format '%s' (use text or json)\n", outputFormat)
+		os.Exit(1)
+	}
+	stats := NewRunStats()
+
+	// Stream the final generation to the terminal as it is produced
+	var renderer *StreamRenderer
+	var summaryStream, outlineStream StreamFunc
+	if !jsonOutput {
+		var stream StreamFunc
+		renderer, stream = newOutputStream(config, useMarkdown)
+		if generateOutline {
+			outlineStream = stream
+		} else {
+			summaryStream = stream
+		}
+	}
+
 	// Process the input (URL or search query)
-	summary, content, title, err := processInput(input, config, length, useMarkdown, enableSearch)
+	summary, content, title, err := processInput(input, config, length, useMarkdown, enableSearch, summaryStream, stats)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
 	// Generate outline if requested
+	var outline string
 	if generateOutline {
-		outline, outlineErr := GenerateOutline(summary, config, useMarkdown, "")
+		generated, outlineErr := GenerateOutline(summary, config, useMarkdown, "", outlineStream, stats)
 		if outlineErr != nil {
 			fmt.Fprintf(os.Stderr, "Error generating outline: %v\n", outlineErr)
 		} else {
-			summary = outline
+			outline = generated
 		}
 	}
 
-	// Display results
-	RenderOutput(summary, useMarkdown, config.DisablePager || disablePager)
-
-	// Handle file saving
-	if saveToFile != "" {
-		if err := SaveToFile(saveToFile, summary); err != nil {
-			fmt.Fprintf(os.Stderr, "Error saving to file: %v\n", err)
-		} else {
-			fmt.Printf("Summary saved to %s\n", saveToFile)
+	if jsonOutput {
+		report := NewSummaryReport(stats, config, title, extractURLFromInput(input), extractQueryFromInput(input), length, summary, outline)
+		if err := report.WriteJSON(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JSON: %v\n", err)
+			os.Exit(1)
 		}
 	}
 
-	// Handle clipboard copying
-	if copyToClipboard {
-		if err := CopyToClipboard(summary); err != nil {
-			fmt.Fprintf(os.Stderr, "Error copying to clipboard: %v\n", err)
-		} else {
-			fmt.Println("Summary copied to clipboard.")
-		}
+	if outline != "" {
+		summary = outline
 	}
 
-	// Start interactive session if enabled
-	if !disableQnA {
-		session := &SessionData{
-			ID:             fmt.Sprintf("session_%d", time.Now().Unix()),
-			Title:          title,
-			URL:            extractURLFromInput(input),
-			Query:          extractQueryFromInput(input),
-			InitialSummary: summary,
-			ContextContent: content,
-			SearchEnabled:  enableSearch,
-			CreatedAt:      time.Now(),
-			LastAccessedAt: time.Now(),
-			Messages: []api.Message{
-				{Role: "system", Content: config.SystemPrompts.QnA},
-				{Role: "assistant", Content: "I'm ready to answer questions about: " + title},
-			},
-		}
-		StartInteractiveSession(session, config, useMarkdown, enableSearch)
+	if jsonOutput {
+		saveAndCopyOutput(summary, output)
+		return
 	}
+	finishOutput(summary, renderer, config, output)
+
+	session := sessionManager.NewSession(title, summary, enableSearch)
+	session.URL = extractURLFromInput(input)
+	session.Query = extractQueryFromInput(input)
+	session.ContextContent = content
+	startQnA(session, config, useMarkdown)
 }
 
 // handleStandaloneFlags processes flags that can be run without other arguments
@@ -219,6 +363,43 @@ func handleStandaloneFlags(config *Config, sessionManager *SessionManager, clean
 	return false
 }
 
+// runBatchMode summarizes every entry of a batch file and returns the exit code
+func runBatchMode(batchFile string, config *Config, opts BatchOptions, llmLimit, fetchLimit int) int {
+	inputs, err := ReadBatchInputs(batchFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading batch file: %v\n", err)
+		return 1
+	}
+	if len(inputs) == 0 {
+		fmt.Fprintln(os.Stderr, "Batch file contains no URLs.")
+		return 1
+	}
+
+	SetConcurrencyLimits(llmLimit, fetchLimit)
+	fmt.Fprintf(os.Stderr, "📦 Summarizing %d sources with %d workers...\n", len(inputs), opts.Workers)
+
+	results := RunBatch(inputs, config, opts)
+	failures, err := WriteBatchReport(results, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing batch report: %v\n", err)
+		return 1
+	}
+
+	if failures > 0 {
+		fmt.Fprintf(os.Stderr, "⚠️ %d of %d sources failed\n", failures, len(inputs))
+		return 1
+	}
+	return 0
+}
+
+// firstPositive returns the flag value when set, otherwise the configured value
+func firstPositive(flagValue, configValue int) int {
+	if flagValue > 0 {
+		return flagValue
+	}
+	return configValue
+}
+
 // resumeSession resumes a saved session
 func resumeSession(sessionName string, sessionManager *SessionManager, config *Config, useMarkdown, enableSearch bool) {
 	session, err := sessionManager.LoadSession(sessionName)
@@ -228,18 +409,25 @@ func resumeSession(sessionName string, sessionManager *SessionManager, config *C
 		os.Exit(1)
 	}
 
+	// Resume with the session's profile unless another one was chosen
+	if session.Profile != "" && config.ActiveProfile == "" {
+		if err := config.ApplyProfile(session.Profile); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
 	fmt.Printf("📂 Resuming session: %s\n", session.GetTitle())
 	StartInteractiveSession(session, config, useMarkdown, session.SearchEnabled)
 }
 
 // processInput handles both URLs and search queries with the new two-stage approach
-func processInput(input string, config *Config, length string, useMarkdown, enableSearch bool) (summary, content, title string, err error) {
+func processInput(input string, config *Config, length string, useMarkdown, enableSearch bool, stream StreamFunc, stats *RunStats) (summary, content, title string, err error) {
 	var sessionID = fmt.Sprintf("temp_%d", time.Now().Unix())
 
-	if IsValidURL(input) {
-		summary, content, title, err = ProcessURL(input, config, length, useMarkdown, enableSearch, sessionID)
+	if source := DetectSource(input, config); source != nil {
+		summary, content, title, err = ProcessSource(source, config, length, useMarkdown, enableSearch, sessionID, stream, stats)
 	} else {
-		summary, content, title, err = ProcessSearchQuery(input, config, length, useMarkdown, sessionID)
+		summary, content, title, err = ProcessSearchQuery(input, config, length, useMarkdown, sessionID, stream, stats)
 	}
 
 	return summary, content, title, err
@@ -254,17 +442,72 @@ func RenderOutput(content string, useMarkdown bool, forceNoPager bool) {
 	}
 }
 
-// extractURLFromInput extracts URL if input is a URL
+// OutputOptions says where a finished summary goes besides the terminal
+type OutputOptions struct {
+	UseMarkdown bool
+	SaveToFile  string
+	Copy        bool
+	Label       string // What is saved or copied, e.g. "Summary", for status messages
+}
+
+// newOutputStream returns a renderer streaming to the terminal, or nils when
+// streaming is disabled
+func newOutputStream(config *Config, useMarkdown bool) (*StreamRenderer, StreamFunc) {
+	if config.DisableStreaming {
+		return nil, nil
+	}
+	renderer := NewStreamRenderer(useMarkdown)
+	return renderer, renderer.Write
+}
+
+// finishOutput shows the text unless the renderer already streamed it (cache
+// hits are not streamed), then saves and copies it as requested
+func finishOutput(text string, renderer *StreamRenderer, config *Config, opts OutputOptions) {
+	if renderer == nil || !renderer.Finish() {
+		RenderOutput(text, opts.UseMarkdown, config.DisablePager)
+	}
+	saveAndCopyOutput(text, opts)
+}
+
+// saveAndCopyOutput writes the text to the output file and the clipboard
+func saveAndCopyOutput(text string, opts OutputOptions) {
+	if opts.SaveToFile != "" {
+		if err := SaveToFile(opts.SaveToFile, text); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving to file: %v\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s saved to %s\n", opts.Label, opts.SaveToFile)
+		}
+	}
+
+	if opts.Copy {
+		if err := CopyToClipboard(text); err != nil {
+			fmt.Fprintf(os.Stderr, "Error copying to clipboard: %v\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s copied to clipboard.\n", opts.Label)
+		}
+	}
+}
+
+// startQnA opens the interactive session unless Q&A is disabled; piped stdin
+// cannot be used for questions
+func startQnA(session *SessionData, config *Config, useMarkdown bool) {
+	if config.DisableQnA || !IsTerminal(os.Stdin) {
+		return
+	}
+	StartInteractiveSession(session, config, useMarkdown, session.SearchEnabled)
+}
+
+// extractURLFromInput extracts the source location if input is a URL or file
 func extractURLFromInput(input string) string {
-	if IsValidURL(input) {
-		return input
+	if source := DetectSource(input, nil); source != nil && source.Location() != "-" {
+		return source.Location()
 	}
 	return ""
 }
 
-// extractQueryFromInput extracts query if input is not a URL
+// extractQueryFromInput extracts query if input is not a URL or file
 func extractQueryFromInput(input string) string {
-	if !IsValidURL(input) {
+	if DetectSource(input, nil) == nil {
 		return input
 	}
 	return ""
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// errOffline is returned for any network access refused by --offline
+var errOffline = errors.New("network access is disabled in offline mode")
+
+// CachedPage is a fetched page as it came off the wire, together with the
+// text extracted from it and the validators for conditional requests
+type CachedPage struct {
+	URL          string    `json:"url"`
+	ContentType  string    `json:"content_type"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Body         []byte    `json:"body"`
+	Title        string    `json:"title"`
+	Text         string    `json:"text"`
+	FetchedAt    time.Time `json:"fetched_at"`
+
+	// Summary cache keys derived from this page, dropped when it changes
+	Dependents []string `json:"dependents,omitempty"`
+}
+
+// pageCacheMu serializes read-modify-write updates of page entries
+var pageCacheMu sync.Mutex
+
+// PageCache stores raw fetched pages by URL. It is kept apart from the
+// summary cache: entries don't expire and survive "cache rm" and
+// --clean-cache, so offline mode can work from everything fetched before.
+type PageCache struct {
+	store  CacheStore
+	config *Config
+}
+
+// NewPageCache opens the page cache, shared across the process like the summary cache
+func NewPageCache(config *Config) *PageCache {
+	configDir, _ := os.UserConfigDir()
+	pagesDir := filepath.Join(configDir, appName, "pages")
+	storeKey := "pages:" + pagesDir
+
+	cacheStores.Lock()
+	defer cacheStores.Unlock()
+
+	store, ok := cacheStores.stores[storeKey]
+	if !ok {
+		store = NewFileCacheStore(pagesDir, int64(config.PageCacheSizeMB)*1024*1024)
+		cacheStores.stores[storeKey] = store
+	}
+	return &PageCache{store: store, config: config}
+}
+
+// pageKey hashes the URL, which may contain characters unfit for file names
+func pageKey(pageURL string) string {
+	return contentHash(pageURL)
+}
+
+// Get returns the cached copy of a page
+func (pc *PageCache) Get(pageURL string) (*CachedPage, bool) {
+	entry, ok := pc.store.Get(pageKey(pageURL))
+	if !ok {
+		return nil, false
+	}
+
+	data, err := json.Marshal(entry.Data)
+	if err != nil {
+		return nil, false
+	}
+	var page CachedPage
+	if json.Unmarshal(data, &page) != nil {
+		return nil, false
+	}
+	return &page, true
+}
+
+// Put stores a freshly fetched page, unless caching is disabled
+func (pc *PageCache) Put(page *CachedPage) error {
+	if !pc.config.CacheEnabled {
+		return nil
+	}
+
+	return pc.store.Put(pageKey(page.URL), &CacheEntry{
+		Data:      page,
+		Timestamp: page.FetchedAt,
+	})
+}
+
+// AddDependent records that a summary cache entry was derived from a page
+func (pc *PageCache) AddDependent(pageURL, cacheKey string) {
+	if !pc.config.CacheEnabled {
+		return
+	}
+
+	pageCacheMu.Lock()
+	defer pageCacheMu.Unlock()
+
+	page, ok := pc.Get(normalizePageURL(pageURL))
+	if !ok || slices.Contains(page.Dependents, cacheKey) {
+		return
+	}
+	page.Dependents = append(page.Dependents, cacheKey)
+	if err := pc.Put(page); err != nil {
+		DebugLog(pc.config, "Failed to record cache dependency for %s: %v", pageURL, err)
+	}
+}
+
+// Update stores a refetched page. If its text changed since the cached
+// copy, the summaries derived from the old text are removed from the cache;
+// otherwise they carry over to the new copy.
+func (pc *PageCache) Update(previous, page *CachedPage) error {
+	pageCacheMu.Lock()
+	defer pageCacheMu.Unlock()
+
+	if previous != nil {
+		if previous.Text == page.Text {
+			page.Dependents = previous.Dependents
+		} else if len(previous.Dependents) > 0 {
+			cacheManager := NewCacheManager(pc.config)
+			for _, key := range previous.Dependents {
+				cacheManager.Delete(key)
+			}
+			DebugLog(pc.config, "%s changed, invalidated %d cached summaries", page.URL, len(previous.Dependents))
+		}
+	}
+	return pc.Put(page)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// pageMarkerPattern matches the page separators inserted by extractPDF
+var pageMarkerPattern = regexp.MustCompile(`(?m)^--- Page \d+ ---$`)
+
+// extractPDF extracts text page by page, keeping "--- Page N ---" markers so
+// summaries and answers can cite page numbers. Title and author come from the
+// document information dictionary when present.
+func extractPDF(data []byte, fallbackTitle string) (content string, title string, err error) {
+	// The PDF parser panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to parse PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open PDF: %v", err)
+	}
+
+	var builder strings.Builder
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		text, err := page.GetPlainText(nil)
+		if err != nil {
+			DebugLog(nil, "Failed to extract text from PDF page %d: %v", i, err)
+			continue
+		}
+
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("--- Page %d ---\n%s\n\n", i, text))
+	}
+
+	title = pdfTitle(reader, fallbackTitle)
+
+	content = strings.TrimSpace(builder.String())
+	if content == "" {
+		return "", title, fmt.Errorf("no extractable text found in PDF (it may be scanned images)")
+	}
+
+	return content, title, nil
+}
+
+// pdfTitle builds a title from the PDF metadata, e.g. "Title by Author"
+func pdfTitle(reader *pdf.Reader, fallbackTitle string) string {
+	info := reader.Trailer().Key("Info")
+	if info.IsNull() {
+		return fallbackTitle
+	}
+
+	title := strings.TrimSpace(info.Key("Title").Text())
+	author := strings.TrimSpace(info.Key("Author").Text())
+
+	if title == "" {
+		title = fallbackTitle
+	}
+	if author != "" {
+		title = fmt.Sprintf("%s by %s", title, author)
+	}
+	return title
+}
+
+// hasPageMarkers reports whether content came from a paged document
+func hasPageMarkers(content string) bool {
+	return pageMarkerPattern.MatchString(content)
+}
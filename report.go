@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// reportSchemaVersion is bumped whenever the JSON output changes incompatibly
+const reportSchemaVersion = 1
+
+// StageStats records how long a pipeline stage took and whether it was cached
+type StageStats struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"duration_ms"`
+	CacheHit   bool   `json:"cache_hit"`
+}
+
+// RunStats collects stage timings and the search results used during a run.
+// All methods are safe to call on a nil receiver, so callers that don't need
+// statistics can simply pass nil.
+type RunStats struct {
+	mu            sync.Mutex
+	started       time.Time
+	stages        []StageStats
+	searchResults []SearchResult
+}
+
+// NewRunStats starts timing a new run
+func NewRunStats() *RunStats {
+	return &RunStats{started: time.Now()}
+}
+
+// Record adds a finished stage
+func (rs *RunStats) Record(name string, start time.Time, cacheHit bool) {
+	if rs == nil {
+		return
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.stages = append(rs.stages, StageStats{
+		Name:       name,
+		DurationMs: time.Since(start).Milliseconds(),
+		CacheHit:   cacheHit,
+	})
+}
+
+// AddSearchResults remembers search results that were fed to the model
+func (rs *RunStats) AddSearchResults(results []SearchResult) {
+	if rs == nil {
+		return
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.searchResults = append(rs.searchResults, results...)
+}
+
+// SearchResults returns the search results used so far
+func (rs *RunStats) SearchResults() []SearchResult {
+	if rs == nil {
+		return nil
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return append([]SearchResult(nil), rs.searchResults...)
+}
+
+// Stages returns the recorded stages in completion order
+func (rs *RunStats) Stages() []StageStats {
+	if rs == nil {
+		return nil
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return append([]StageStats(nil), rs.stages...)
+}
+
+// SummaryReport is the stable machine-readable output of --format json
+type SummaryReport struct {
+	SchemaVersion int            `json:"schema_version"`
+	Title         string         `json:"title"`
+	SourceURL     string         `json:"source_url,omitempty"`
+	Query         string         `json:"query,omitempty"`
+	Model         string         `json:"model"`
+	Length        string         `json:"length"`
+	Summary       string         `json:"summary"`
+	Outline       string         `json:"outline,omitempty"`
+	SearchResults []SearchResult `json:"search_results"`
+	Stages        []StageStats   `json:"stages"`
+	TotalMs       int64          `json:"total_ms"`
+	CacheHit      bool           `json:"cache_hit"`
+}
+
+// NewSummaryReport assembles a report from a finished run
+func NewSummaryReport(stats *RunStats, config *Config, title, sourceURL, query, length, summary, outline string) *SummaryReport {
+	model, _ := config.ModelFor(TaskSummary)
+	report := &SummaryReport{
+		SchemaVersion: reportSchemaVersion,
+		Title:         title,
+		SourceURL:     sourceURL,
+		Query:         query,
+		Model:         model,
+		Length:        length,
+		Summary:       summary,
+		Outline:       outline,
+		SearchResults: stats.SearchResults(),
+		Stages:        stats.Stages(),
+	}
+
+	if report.SearchResults == nil {
+		report.SearchResults = []SearchResult{}
+	}
+	if report.Stages == nil {
+		report.Stages = []StageStats{}
+	}
+	if stats != nil {
+		report.TotalMs = time.Since(stats.started).Milliseconds()
+	}
+
+	// The run counts as a cache hit when the final summary came from the cache
+	for _, stage := range report.Stages {
+		if stage.Name == "summary" && stage.CacheHit {
+			report.CacheHit = true
+		}
+	}
+
+	return report
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *SummaryReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// embedBatchSize limits how many chunks are sent in one embedding request
+const embedBatchSize = 32
+
+// IndexedChunk is a piece of a document together with its embedding
+type IndexedChunk struct {
+	Position  int       `json:"position"`
+	Text      string    `json:"text"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// DocumentIndex holds the embedded chunks of one document
+type DocumentIndex struct {
+	Model       string         `json:"model"`
+	ContentHash string         `json:"content_hash"`
+	ChunkTokens int            `json:"chunk_tokens"`
+	Chunks      []IndexedChunk `json:"chunks"`
+}
+
+// ScoredChunk is a retrieval hit
+type ScoredChunk struct {
+	IndexedChunk
+	Score float64
+}
+
+// indexCache keeps indexes in memory between questions of the same session.
+// Building an index is serialized per session, not across sessions.
+var indexCache = struct {
+	sync.Mutex
+	indexes map[string]*DocumentIndex
+	locks   map[string]*sync.Mutex
+}{indexes: make(map[string]*DocumentIndex), locks: make(map[string]*sync.Mutex)}
+
+// contentHash identifies the text an index was built from
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// sourceHeaderPattern finds the source headers of multi-document content
+var sourceHeaderPattern = regexp.MustCompile(`(?m)^=== Source \[\d+\]: .* ===$`)
+
+// chunkSources chunks each source of multi-document content on its own and
+// repeats its header on every chunk, so retrieved excerpts stay attributed.
+// PDF page markers are carried onto chunks the same way.
+func chunkSources(content string, chunkTokens int) []string {
+	headers := sourceHeaderPattern.FindAllStringIndex(content, -1)
+	if len(headers) == 0 {
+		return carryPageMarkers(ChunkText(content, chunkTokens, chunkTokens/8))
+	}
+
+	var chunks []string
+	for i, loc := range headers {
+		end := len(content)
+		if i+1 < len(headers) {
+			end = headers[i+1][0]
+		}
+		header := content[loc[0]:loc[1]]
+		for _, chunk := range carryPageMarkers(ChunkText(strings.TrimSpace(content[loc[1]:end]), chunkTokens, chunkTokens/8)) {
+			chunks = append(chunks, header+"\n"+chunk)
+		}
+	}
+	return chunks
+}
+
+// carryPageMarkers starts every chunk that begins within a page with that
+// page's "--- Page N ---" marker, so excerpts can be cited by page
+func carryPageMarkers(chunks []string) []string {
+	page := "" // The marker in effect where the previous chunk starts
+	var previous []string
+	for i, chunk := range chunks {
+		lines := strings.Split(chunk, "\n")
+		if i > 0 {
+			// Markers in the overlap with this chunk come after its start
+			start := len(previous) - overlapLines(previous, lines)
+			for _, line := range previous[:start] {
+				if pageMarkerPattern.MatchString(line) {
+					page = line
+				}
+			}
+		}
+		if page != "" && !pageMarkerPattern.MatchString(lines[0]) {
+			chunks[i] = page + "\n" + chunk
+		}
+		previous = lines
+	}
+	return chunks
+}
+
+// overlapLines returns how many trailing lines of previous the next chunk
+// starts with, as carried over by ChunkText
+func overlapLines(previous, next []string) int {
+	for n := Min(len(previous)-1, len(next)); n > 0; n-- {
+		if slices.Equal(previous[len(previous)-n:], next[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// BuildIndex chunks the content and embeds every chunk
+func BuildIndex(ctx context.Context, config *Config, provider LLMProvider, content string) (*DocumentIndex, error) {
+	chunkTokens := Max(config.Retrieval.ChunkTokens, 64)
+	chunks := chunkSources(content, chunkTokens)
+
+	index := &DocumentIndex{
+		Model:       config.Retrieval.EmbeddingModel,
+		ContentHash: contentHash(content),
+		ChunkTokens: chunkTokens,
+	}
+
+	for start := 0; start < len(chunks); start += embedBatchSize {
+		batch := chunks[start:Min(start+embedBatchSize, len(chunks))]
+
+		release := acquireLLMSlot()
+		embeddings, err := provider.Embed(ctx, index.Model, batch)
+		release()
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed document with %s: %v", index.Model, err)
+		}
+
+		for i, text := range batch {
+			index.Chunks = append(index.Chunks, IndexedChunk{
+				Position:  start + i,
+				Text:      text,
+				Embedding: embeddings[i],
+			})
+		}
+	}
+
+	return index, nil
+}
+
+// Matches reports whether the index was built from content with the configured settings
+func (idx *DocumentIndex) Matches(config *Config, content string) bool {
+	return idx != nil &&
+		idx.Model == config.Retrieval.EmbeddingModel &&
+		idx.ChunkTokens == Max(config.Retrieval.ChunkTokens, 64) &&
+		idx.ContentHash == contentHash(content)
+}
+
+// Search returns the k chunks most similar to the query embedding
+func (idx *DocumentIndex) Search(query []float32, k int) []ScoredChunk {
+	scored := make([]ScoredChunk, 0, len(idx.Chunks))
+	for _, chunk := range idx.Chunks {
+		scored = append(scored, ScoredChunk{IndexedChunk: chunk, Score: cosineSimilarity(query, chunk.Embedding)})
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
+	})
+	if len(scored) > k {
+		scored = scored[:k]
+	}
+	return scored
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// indexPath stores the index next to the session file. The extension keeps
+// it out of ListSessions.
+func (sm *SessionManager) indexPath(sessionID string) string {
+	return filepath.Join(sm.sessionsDir, sessionID+".index")
+}
+
+// SaveIndex persists a session's document index
+func (sm *SessionManager) SaveIndex(sessionID string, index *DocumentIndex) error {
+	if !sm.config.SessionPersist {
+		return nil
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(sm.indexPath(sessionID), data, 0644)
+}
+
+// LoadIndex reads a session's document index from disk
+func (sm *SessionManager) LoadIndex(sessionID string) (*DocumentIndex, error) {
+	data, err := os.ReadFile(sm.indexPath(sessionID))
+	if err != nil {
+		return nil, err
+	}
+
+	var index DocumentIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
+
+// sessionIndex returns an up-to-date index for the session's content, from
+// memory, from disk or by embedding the document
+func sessionIndex(ctx context.Context, session *SessionData, config *Config, provider LLMProvider) (*DocumentIndex, error) {
+	// Questions to the same session wait for one build instead of each
+	// embedding the document; other sessions are not held up
+	indexCache.Lock()
+	lock, ok := indexCache.locks[session.ID]
+	if !ok {
+		lock = &sync.Mutex{}
+		indexCache.locks[session.ID] = lock
+	}
+	indexCache.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	indexCache.Lock()
+	index := indexCache.indexes[session.ID]
+	indexCache.Unlock()
+
+	content := session.Content()
+	if index.Matches(config, content) {
+		return index, nil
+	}
+
+	sessionManager := NewSessionManager(config)
+	if index, err := sessionManager.LoadIndex(session.ID); err == nil && index.Matches(config, content) {
+		publishIndex(session.ID, index)
+		return index, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "🔎 Indexing document for questions...\n")
+	index, err := BuildIndex(ctx, config, provider, content)
+	if err != nil {
+		return nil, err
+	}
+
+	publishIndex(session.ID, index)
+	if err := sessionManager.SaveIndex(session.ID, index); err != nil {
+		DebugLog(config, "Failed to save index for session %s: %v", session.ID, err)
+	}
+	return index, nil
+}
+
+// RetrieveContext selects the parts of the session's document that are most
+// relevant to the question. Short documents are returned whole; if embedding
+// fails, the opening of the document is used instead.
+func RetrieveContext(ctx context.Context, question string, session *SessionData, config *Config, provider LLMProvider) string {
+	content := session.Content()
+	topK := Max(config.Retrieval.TopK, 1)
+	if EstimateTokens(content) <= topK*Max(config.Retrieval.ChunkTokens, 64) {
+		return content
+	}
+
+	fallback := truncateTokens(content, 500)
+
+	index, err := sessionIndex(ctx, session, config, provider)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Retrieval unavailable, using the start of the document: %v\n", err)
+		return fallback
+	}
+
+	release := acquireLLMSlot()
+	embeddings, err := provider.Embed(ctx, index.Model, []string{question})
+	release()
+	if err != nil || len(embeddings) == 0 {
+		DebugLog(config, "Failed to embed question: %v", err)
+		return fallback
+	}
+
+	hits := index.Search(embeddings[0], topK)
+	DebugLog(config, "Retrieved %d of %d chunks for question", len(hits), len(index.Chunks))
+
+	// Present the passages in document order so they read naturally
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].Position < hits[j].Position
+	})
+
+	passages := make([]string, len(hits))
+	for i, hit := range hits {
+		passages[i] = fmt.Sprintf("[Excerpt %d]\n%s", hit.Position+1, hit.Text)
+	}
+	return strings.Join(passages, "\n\n")
+}
+
+// publishIndex makes a session's index available to later questions
+func publishIndex(sessionID string, index *DocumentIndex) {
+	indexCache.Lock()
+	indexCache.indexes[sessionID] = index
+	indexCache.Unlock()
+}
+
+// forgetIndex drops a deleted session's index and build lock from memory
+func forgetIndex(sessionID string) {
+	indexCache.Lock()
+	delete(indexCache.indexes, sessionID)
+	delete(indexCache.locks, sessionID)
+	indexCache.Unlock()
+}
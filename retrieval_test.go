@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkSourcesCarriesHeadersAndPages(t *testing.T) {
+	var pdf strings.Builder
+	for page := 1; page <= 3; page++ {
+		pdf.WriteString(fmt.Sprintf("--- Page %d ---\n", page))
+		for line := 1; line <= 8; line++ {
+			pdf.WriteString(fmt.Sprintf("Page %d line %d says something worth indexing.\n", page, line))
+		}
+		pdf.WriteString("\n")
+	}
+	content := fmt.Sprintf(sourceHeaderFormat, 1, "Report", "report.pdf") + "\n\n" + pdf.String() + "\n" +
+		fmt.Sprintf(sourceHeaderFormat, 2, "Notes", "https://notes.example") + "\n\nShort notes without pages."
+
+	chunks := chunkSources(content, 64)
+	if len(chunks) < 4 {
+		t.Fatalf("got %d chunks, want the report split into several", len(chunks))
+	}
+
+	for _, chunk := range chunks {
+		lines := strings.Split(chunk, "\n")
+		if !sourceHeaderPattern.MatchString(lines[0]) {
+			t.Fatalf("chunk does not start with a source header:\n%s", chunk)
+		}
+		if strings.Contains(lines[0], "Notes") {
+			if strings.Contains(chunk, "--- Page") {
+				t.Errorf("page marker leaked into another source:\n%s", chunk)
+			}
+			continue
+		}
+
+		// The page marker in effect must match the first line of text
+		if !pageMarkerPattern.MatchString(lines[1]) {
+			t.Fatalf("chunk has no page marker after its header:\n%s", chunk)
+		}
+		var page, firstPage int
+		fmt.Sscanf(lines[1], "--- Page %d ---", &page)
+		fmt.Sscanf(lines[2], "Page %d", &firstPage)
+		if pageMarkerPattern.MatchString(lines[2]) {
+			t.Errorf("chunk repeats a marker:\n%s", chunk)
+		}
+		if page != firstPage {
+			t.Errorf("chunk starting on page %d is marked page %d:\n%s", firstPage, page, chunk)
+		}
+	}
+}
+
+func TestCarryPageMarkersOverlap(t *testing.T) {
+	// The second chunk starts with lines carried over from page 1
+	chunks := carryPageMarkers([]string{
+		"--- Page 1 ---\na\nb\n--- Page 2 ---\nc",
+		"b\n--- Page 2 ---\nc\nd",
+		"c\nd\ne",
+	})
+	want := []string{
+		"--- Page 1 ---\na\nb\n--- Page 2 ---\nc",
+		"--- Page 1 ---\nb\n--- Page 2 ---\nc\nd",
+		"--- Page 2 ---\nc\nd\ne",
+	}
+	for i := range want {
+		if chunks[i] != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, chunks[i], want[i])
+		}
+	}
+}
+
+func TestCarryPageMarkersWithoutPages(t *testing.T) {
+	chunks := []string{"first\nsecond", "second\nthird"}
+	got := carryPageMarkers(append([]string(nil), chunks...))
+	if strings.Join(got, "|") != strings.Join(chunks, "|") {
+		t.Errorf("chunks without markers changed: %q", got)
+	}
+}
+
+// embedFailingProvider fails every embedding request
+type embedFailingProvider struct {
+	LLMProvider
+}
+
+func (embedFailingProvider) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	return nil, errors.New("no embedding model")
+}
+
+func TestRetrieveContextFallbackKeepsCharacters(t *testing.T) {
+	config := newTestConfig(t)
+	session := &SessionData{ID: "fallback", ContextContent: "ab" + strings.Repeat("äöü ", 5000)}
+
+	got := RetrieveContext(context.Background(), "question", session, config, embedFailingProvider{})
+	if !utf8.ValidString(got) {
+		t.Fatalf("fallback context is not valid UTF-8: %q", got[len(got)-20:])
+	}
+	if !strings.HasPrefix(got, "abäöü") || len(got) >= len(session.ContextContent) {
+		t.Errorf("fallback is not the start of the document: %q...", got[:20])
+	}
+}
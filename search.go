@@ -1,10 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +23,7 @@ type SearchResult struct {
 	URL     string `json:"url"`
 	Snippet string `json:"snippet"`
 	Source  string `json:"source"`
+	Content string `json:"excerpt,omitempty"` // Page excerpts in deep-search mode
 }
 
 // SearchEngine interface for different search implementations
@@ -105,52 +111,306 @@ func (d *DuckDuckGoEngine) Search(query string, limit int) ([]SearchResult, erro
 	return results, nil
 }
 
-// SearchManager manages the search engine with caching and optimization
+// SearXNGEngine queries a self-hosted SearXNG instance through its JSON API.
+// The instance must list "json" under search.formats in its settings.yml.
+type SearXNGEngine struct {
+	baseURL    string
+	categories string
+	language   string
+	safeSearch int
+	client     *http.Client
+}
+
+func NewSearXNGEngine(config SearXNGConfig) *SearXNGEngine {
+	return &SearXNGEngine{
+		baseURL:    strings.TrimRight(config.BaseURL, "/"),
+		categories: config.Categories,
+		language:   config.Language,
+		safeSearch: config.SafeSearch,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (s *SearXNGEngine) Name() string {
+	return "SearXNG"
+}
+
+type searxngResponse struct {
+	Results []struct {
+		Title   string `json:"title"`
+		URL     string `json:"url"`
+		Content string `json:"content"`
+	} `json:"results"`
+}
+
+// Search calls the /search endpoint with format=json.
+func (s *SearXNGEngine) Search(query string, limit int) ([]SearchResult, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("format", "json")
+	if s.categories != "" {
+		params.Set("categories", s.categories)
+	}
+	if s.language != "" {
+		params.Set("language", s.language)
+	}
+	params.Set("safesearch", strconv.Itoa(s.safeSearch))
+
+	req, err := http.NewRequest("GET", s.baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("SearXNG refused the JSON format; enable it under search.formats in settings.yml")
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("SearXNG search failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var response searxngResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode SearXNG response: %v", err)
+	}
+
+	var results []SearchResult
+	for _, item := range response.Results {
+		if len(results) >= limit {
+			break
+		}
+		if item.Title == "" || item.URL == "" {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			Title:   strings.TrimSpace(item.Title),
+			URL:     item.URL,
+			Snippet: strings.TrimSpace(item.Content),
+			Source:  s.Name(),
+		})
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no results found on SearXNG for '%s'", query)
+	}
+
+	return results, nil
+}
+
+// rrfK dampens the influence of top ranks in reciprocal rank fusion
+const rrfK = 60
+
+// newSearchEngine creates a search engine by its config name
+func newSearchEngine(name string, config *Config) (SearchEngine, error) {
+	switch strings.ToLower(name) {
+	case "searxng":
+		if config.Search.SearXNG.BaseURL == "" {
+			return nil, fmt.Errorf("search.searxng.base_url is not set")
+		}
+		return NewSearXNGEngine(config.Search.SearXNG), nil
+	case "duckduckgo", "ddg":
+		return NewDuckDuckGoEngine(), nil
+	default:
+		return nil, fmt.Errorf("unknown search engine '%s'", name)
+	}
+}
+
+// SearchManager manages the search engines with caching and optimization
 type SearchManager struct {
-	engine SearchEngine
-	config *Config
-	cache  *CacheManager
+	engines []SearchEngine
+	fusion  bool
+	config  *Config
+	cache   *CacheManager
 }
 
 func NewSearchManager(config *Config) *SearchManager {
 	sm := &SearchManager{
 		config: config,
 		cache:  NewCacheManager(config),
-		engine: NewDuckDuckGoEngine(),
+		fusion: strings.EqualFold(config.Search.Mode, "fusion"),
 	}
 
-	DebugLog(config, "Search manager initialized with %s engine", sm.engine.Name())
+	for _, name := range config.Search.Engines {
+		engine, err := newSearchEngine(name, config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Skipping search engine: %v\n", err)
+			continue
+		}
+		sm.engines = append(sm.engines, engine)
+	}
+	if len(sm.engines) == 0 {
+		sm.engines = []SearchEngine{NewDuckDuckGoEngine()}
+	}
+
+	DebugLog(config, "Search manager initialized with %s (fusion: %t)", sm.engineNames(), sm.fusion)
 	return sm
 }
 
+// engineNames lists the configured engines in order
+func (sm *SearchManager) engineNames() string {
+	names := make([]string, len(sm.engines))
+	for i, engine := range sm.engines {
+		names[i] = engine.Name()
+	}
+	return strings.Join(names, ",")
+}
+
 // Search performs a cached search.
 func (sm *SearchManager) Search(query string, limit int, sessionID string) ([]SearchResult, error) {
-	cacheKey := sm.cache.GetCacheKey(fmt.Sprintf("search:%s:%d", query, limit))
+	cacheKey := sm.cache.GetCacheKey("results", sm.engineNames(), sm.fusion, sm.config.Search.SearXNG, query, limit)
 	var cachedResults []SearchResult
 	if sm.cache.Get(cacheKey, &cachedResults) {
 		DebugLog(sm.config, "Cache hit for search: %s", query)
 		return cachedResults, nil
 	}
 
+	if sm.config.Offline {
+		return nil, fmt.Errorf("no cached results for '%s': %w", query, errOffline)
+	}
+
 	DebugLog(sm.config, "Cache miss, performing search: %s", query)
 
-	results, err := sm.engine.Search(query, limit)
+	var results []SearchResult
+	var err error
+	if sm.fusion && len(sm.engines) > 1 {
+		results, err = sm.searchFusion(query, limit)
+	} else {
+		results, err = sm.searchFallback(query, limit)
+	}
 	if err != nil {
-		DebugLog(sm.config, "%s search failed: %v", sm.engine.Name(), err)
 		return nil, err
 	}
 
 	if len(results) > 0 {
 		sm.cache.Set(cacheKey, results, sessionID)
-		DebugLog(sm.config, "%s search successful: %d results", sm.engine.Name(), len(results))
 	}
 
 	return results, nil
 }
 
+// searchFallback tries each engine in order until one returns results
+func (sm *SearchManager) searchFallback(query string, limit int) ([]SearchResult, error) {
+	var lastErr error
+	for _, engine := range sm.engines {
+		results, err := engine.Search(query, limit)
+		if err != nil {
+			DebugLog(sm.config, "%s search failed: %v", engine.Name(), err)
+			lastErr = err
+			continue
+		}
+		if len(results) == 0 {
+			DebugLog(sm.config, "%s returned no results", engine.Name())
+			continue
+		}
+
+		DebugLog(sm.config, "%s search successful: %d results", engine.Name(), len(results))
+		return results, nil
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, nil
+}
+
+// searchFusion queries all engines concurrently and merges their rankings
+// with reciprocal rank fusion
+func (sm *SearchManager) searchFusion(query string, limit int) ([]SearchResult, error) {
+	rankings := make([][]SearchResult, len(sm.engines))
+	errs := make([]error, len(sm.engines))
+
+	var wg sync.WaitGroup
+	for i, engine := range sm.engines {
+		wg.Add(1)
+		go func(i int, engine SearchEngine) {
+			defer wg.Done()
+			rankings[i], errs[i] = engine.Search(query, limit)
+			if errs[i] != nil {
+				DebugLog(sm.config, "%s search failed: %v", engine.Name(), errs[i])
+			}
+		}(i, engine)
+	}
+	wg.Wait()
+
+	fused := fuseResults(rankings, limit)
+	if len(fused) == 0 {
+		for _, err := range errs {
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	DebugLog(sm.config, "Fused %d engines into %d results", len(sm.engines), len(fused))
+	return fused, nil
+}
+
+// fuseResults merges ranked result lists by reciprocal rank fusion: each
+// result scores the sum of 1/(k+rank) over the lists it appears in. Results
+// found by several engines list all of them in Source.
+func fuseResults(rankings [][]SearchResult, limit int) []SearchResult {
+	type fusedResult struct {
+		result  SearchResult
+		score   float64
+		order   int
+		sources []string
+	}
+
+	byURL := make(map[string]*fusedResult)
+	var merged []*fusedResult
+
+	for _, ranking := range rankings {
+		for rank, result := range ranking {
+			key := strings.TrimRight(result.URL, "/")
+			entry, exists := byURL[key]
+			if !exists {
+				entry = &fusedResult{result: result, order: len(merged)}
+				byURL[key] = entry
+				merged = append(merged, entry)
+			} else if len(result.Snippet) > len(entry.result.Snippet) {
+				entry.result.Snippet = result.Snippet
+			}
+			entry.score += 1.0 / float64(rrfK+rank+1)
+			if !slices.Contains(entry.sources, result.Source) {
+				entry.sources = append(entry.sources, result.Source)
+			}
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].score != merged[j].score {
+			return merged[i].score > merged[j].score
+		}
+		return merged[i].order < merged[j].order
+	})
+
+	var results []SearchResult
+	for _, entry := range merged {
+		if len(results) >= limit {
+			break
+		}
+		entry.result.Source = strings.Join(entry.sources, ", ")
+		results = append(results, entry.result)
+	}
+	return results
+}
+
 // PerformParallelSearches performs multiple searches with improved efficiency
 func (sm *SearchManager) PerformParallelSearches(queries []string, limitPerQuery int, sessionID string) []SearchResult {
 	DebugLog(sm.config, "Starting parallel searches for %d queries", len(queries))
+	if sm.config.Offline {
+		fmt.Fprintf(os.Stderr, "📴 Offline: using previously cached search results only\n")
+	}
 
 	var wg sync.WaitGroup
 	var mu sync.Mutex
@@ -185,6 +445,10 @@ func (sm *SearchManager) PerformParallelSearches(queries []string, limitPerQuery
 	}
 
 	DebugLog(sm.config, "Parallel searches completed: %d unique results", len(uniqueResults))
+
+	if sm.config.Search.DeepSearch {
+		uniqueResults = sm.FetchResultPages(uniqueResults, strings.Join(queries, " "))
+	}
 	return uniqueResults
 }
 
@@ -214,6 +478,11 @@ func FormatSearchResults(results []SearchResult) string {
 	builder.WriteString("\n\n--- ADDITIONAL CONTEXT FROM WEB SEARCH ---\n")
 
 	for i, result := range results {
+		if result.Content != "" {
+			builder.WriteString(fmt.Sprintf("\n[%d] %s\nExcerpts:\n%s\nSource: %s <%s>\n",
+				i+1, result.Title, result.Content, result.Source, result.URL))
+			continue
+		}
 		builder.WriteString(fmt.Sprintf("\n[%d] %s\nSnippet: %s\nSource: %s <%s>\n",
 			i+1, result.Title, result.Snippet, result.Source, result.URL))
 	}
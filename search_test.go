@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestConfig returns defaults with caching off and the config dir in a
+// temporary directory, so tests never touch the user's cache
+func newTestConfig(t *testing.T) *Config {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	config := createDefaultConfig()
+	config.CacheEnabled = false
+	return config
+}
+
+// searxngJSON builds a SearXNG response with n results
+func searxngJSON(n int) string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"title": " Result %d ", "url": "https://example.com/%d", "content": " snippet %d "}`, i+1, i+1, i+1)
+	}
+	return `{"query": "q", "results": [` + strings.Join(items, ",") + `]}`
+}
+
+func TestSearXNGQueryParameters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" {
+			t.Errorf("path = %s, want /search", r.URL.Path)
+		}
+		query := r.URL.Query()
+		want := map[string]string{
+			"q":          "rust async",
+			"format":     "json",
+			"categories": "it",
+			"language":   "en",
+			"safesearch": "2",
+		}
+		for key, value := range want {
+			if got := query.Get(key); got != value {
+				t.Errorf("%s = %q, want %q", key, got, value)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, searxngJSON(1))
+	}))
+	defer server.Close()
+
+	engine := NewSearXNGEngine(SearXNGConfig{
+		BaseURL:    server.URL + "/",
+		Categories: "it",
+		Language:   "en",
+		SafeSearch: 2,
+	})
+	if _, err := engine.Search("rust async", 5); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+}
+
+func TestSearXNGDecodesAndLimitsResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"results": [
+			{"title": "No URL", "url": "", "content": "skipped"},
+			`+strings.TrimSuffix(strings.TrimPrefix(searxngJSON(5), `{"query": "q", "results": [`), `]}`)+`
+		]}`)
+	}))
+	defer server.Close()
+
+	results, err := NewSearXNGEngine(SearXNGConfig{BaseURL: server.URL}).Search("q", 3)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	first := results[0]
+	if first.Title != "Result 1" || first.URL != "https://example.com/1" || first.Snippet != "snippet 1" || first.Source != "SearXNG" {
+		t.Errorf("unexpected first result: %+v", first)
+	}
+}
+
+func TestSearXNGErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"json format disabled", http.StatusForbidden, "Forbidden", "search.formats"},
+		{"server error", http.StatusInternalServerError, "boom", "status 500: boom"},
+		{"invalid json", http.StatusOK, "<html>", "failed to decode"},
+		{"no results", http.StatusOK, `{"results": []}`, "no results"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			_, err := NewSearXNGEngine(SearXNGConfig{BaseURL: server.URL}).Search("q", 5)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// fakeEngine returns fixed results or an error and counts its calls
+type fakeEngine struct {
+	name    string
+	results []SearchResult
+	err     error
+	calls   int
+}
+
+func (f *fakeEngine) Name() string { return f.name }
+
+func (f *fakeEngine) Search(query string, limit int) ([]SearchResult, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.results, nil
+}
+
+func TestSearchFallbackOrder(t *testing.T) {
+	config := newTestConfig(t)
+
+	failing := &fakeEngine{name: "first", err: errors.New("unreachable")}
+	empty := &fakeEngine{name: "second"}
+	answering := &fakeEngine{name: "third", results: []SearchResult{{Title: "T", URL: "https://t", Source: "third"}}}
+	unused := &fakeEngine{name: "fourth", results: []SearchResult{{Title: "U", URL: "https://u", Source: "fourth"}}}
+
+	sm := &SearchManager{config: config, cache: NewCacheManager(config), engines: []SearchEngine{failing, empty, answering, unused}}
+	results, err := sm.Search("q", 5, "")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].Source != "third" {
+		t.Fatalf("results = %+v, want the third engine's", results)
+	}
+	if failing.calls != 1 || empty.calls != 1 || unused.calls != 0 {
+		t.Errorf("calls = %d, %d, %d; want 1, 1, 0", failing.calls, empty.calls, unused.calls)
+	}
+
+	// When every engine fails, the last error is reported
+	sm.engines = []SearchEngine{failing, &fakeEngine{name: "other", err: errors.New("last failure")}}
+	if _, err := sm.Search("q2", 5, ""); err == nil || err.Error() != "last failure" {
+		t.Errorf("error = %v, want the last failure", err)
+	}
+}
+
+func TestSearchFallbackAgainstSearXNG(t *testing.T) {
+	config := newTestConfig(t)
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, searxngJSON(2))
+	}))
+	defer up.Close()
+
+	sm := &SearchManager{config: config, cache: NewCacheManager(config), engines: []SearchEngine{
+		NewSearXNGEngine(SearXNGConfig{BaseURL: down.URL}),
+		NewSearXNGEngine(SearXNGConfig{BaseURL: up.URL}),
+	}}
+	results, err := sm.Search("q", 5, "")
+	if err != nil || len(results) != 2 {
+		t.Fatalf("got %d results, err %v; want 2 from the second instance", len(results), err)
+	}
+}
+
+func TestPerformParallelSearchesLimitsResults(t *testing.T) {
+	config := newTestConfig(t)
+	config.MaxSearchResults = 4
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, searxngJSON(10))
+	}))
+	defer server.Close()
+
+	sm := &SearchManager{config: config, cache: NewCacheManager(config), engines: []SearchEngine{
+		NewSearXNGEngine(SearXNGConfig{BaseURL: server.URL}),
+	}}
+	results := sm.PerformParallelSearches([]string{"a", "b"}, 10, "")
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want MaxSearchResults = 4", len(results))
+	}
+}
+
+func TestFuseResultsReciprocalRank(t *testing.T) {
+	engineA := []SearchResult{
+		{Title: "Shared", URL: "https://shared.example/", Snippet: "short", Source: "A"},
+		{Title: "Only A", URL: "https://a.example", Source: "A"},
+	}
+	engineB := []SearchResult{
+		{Title: "Only B", URL: "https://b.example", Source: "B"},
+		{Title: "Shared", URL: "https://shared.example", Snippet: "a longer snippet", Source: "B"},
+	}
+
+	fused := fuseResults([][]SearchResult{engineA, engineB}, 10)
+	if len(fused) != 3 {
+		t.Fatalf("got %d results, want 3 after deduplicating by URL", len(fused))
+	}
+
+	// Shared: 1/61 + 1/62 beats a single first place at 1/61
+	shared := fused[0]
+	if shared.Title != "Shared" || shared.Source != "A, B" || shared.Snippet != "a longer snippet" {
+		t.Errorf("unexpected top result: %+v", shared)
+	}
+	if rrfK != 60 {
+		t.Errorf("rrfK = %d, want 60", rrfK)
+	}
+
+	// A single first place (1/61) beats a single second place (1/62)
+	if fused[1].Title != "Only B" || fused[2].Title != "Only A" {
+		t.Errorf("order = %q, %q; want Only B (rank 1) before Only A (rank 2)", fused[1].Title, fused[2].Title)
+	}
+
+	if limited := fuseResults([][]SearchResult{engineA, engineB}, 2); len(limited) != 2 {
+		t.Errorf("got %d results, want the limit of 2", len(limited))
+	}
+}
+
+func TestSearchFusionSkipsFailedEngines(t *testing.T) {
+	config := newTestConfig(t)
+	config.Search.Mode = "fusion"
+
+	sm := &SearchManager{config: config, cache: NewCacheManager(config), fusion: true, engines: []SearchEngine{
+		&fakeEngine{name: "broken", err: errors.New("down")},
+		&fakeEngine{name: "B", results: []SearchResult{{Title: "B1", URL: "https://b1", Source: "B"}}},
+	}}
+	results, err := sm.Search("q", 5, "")
+	if err != nil || len(results) != 1 || results[0].Source != "B" {
+		t.Fatalf("results = %+v, err = %v", results, err)
+	}
+
+	sm.engines = []SearchEngine{
+		&fakeEngine{name: "x", err: errors.New("down")},
+		&fakeEngine{name: "y", err: errors.New("down")},
+	}
+	if _, err := sm.Search("q2", 5, ""); err == nil {
+		t.Error("expected an error when every engine fails")
+	}
+}
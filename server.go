@@ -0,0 +1,588 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// defaultServeAddr listens on loopback only, on a port that doesn't clash
+// with the llama.cpp server default the OpenAI provider expects on 8080
+const defaultServeAddr = "127.0.0.1:8787"
+
+// maxRequestBodyBytes bounds request bodies, which hold at most one document
+const maxRequestBodyBytes = 16 << 20
+
+// Server exposes summarization and Q&A sessions as a local REST API
+type Server struct {
+	config         *Config
+	provider       LLMProvider
+	sessionManager *SessionManager
+	searchManager  *SearchManager
+	cacheManager   *CacheManager
+
+	mu           sync.Mutex
+	sessions     map[string]*SessionData
+	sessionLocks map[string]*sync.Mutex // Serialize questions per session
+}
+
+// summarizeRequest is the JSON body accepted by the summarize endpoints
+type summarizeRequest struct {
+	URL      string `json:"url"`
+	Text     string `json:"text"`
+	Title    string `json:"title"`
+	Query    string `json:"query"`
+	Summary  string `json:"summary"`
+	Length   string `json:"length"`
+	Markdown bool   `json:"markdown"`
+	Search   bool   `json:"search"`
+	Outline  bool   `json:"outline"`
+	Stream   bool   `json:"stream"`
+}
+
+// askRequest is the JSON body for asking a question in a session
+type askRequest struct {
+	Question string `json:"question"`
+	Stream   bool   `json:"stream"`
+}
+
+// sessionInfo is the API view of a session, without the full document text
+type sessionInfo struct {
+	ID             string    `json:"id"`
+	Title          string    `json:"title"`
+	URL            string    `json:"url,omitempty"`
+	Query          string    `json:"query,omitempty"`
+	Summary        string    `json:"summary"`
+	SearchEnabled  bool      `json:"search_enabled"`
+	MessageCount   int       `json:"message_count"`
+	CreatedAt      time.Time `json:"created_at"`
+	LastAccessedAt time.Time `json:"last_accessed_at"`
+
+	Sources []sessionSource `json:"sources,omitempty"` // Multi-document sessions
+}
+
+type sessionSource struct {
+	Title string `json:"title"`
+	URL   string `json:"url,omitempty"`
+}
+
+// NewServer creates an API server backed by the regular managers
+func NewServer(config *Config) (*Server, error) {
+	provider, err := NewLLMProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		config:         config,
+		provider:       provider,
+		sessionManager: NewSessionManager(config),
+		searchManager:  NewSearchManager(config),
+		cacheManager:   NewCacheManager(config),
+		sessions:       make(map[string]*SessionData),
+		sessionLocks:   make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// Handler returns the HTTP routes of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/summarize/url", s.handleSummarizeURL)
+	mux.HandleFunc("POST /api/summarize/text", s.handleSummarizeText)
+	mux.HandleFunc("POST /api/search", s.handleSearch)
+	mux.HandleFunc("POST /api/outline", s.handleOutline)
+	mux.HandleFunc("GET /api/sessions", s.handleListSessions)
+	mux.HandleFunc("POST /api/sessions", s.handleCreateSession)
+	mux.HandleFunc("GET /api/sessions/{id}", s.handleGetSession)
+	mux.HandleFunc("DELETE /api/sessions/{id}", s.handleDeleteSession)
+	mux.HandleFunc("POST /api/sessions/{id}/ask", s.handleAsk)
+	mux.HandleFunc("GET /api/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "version": version})
+	})
+	return http.MaxBytesHandler(mux, maxRequestBodyBytes)
+}
+
+// RunServer starts the API server and blocks until it fails
+func RunServer(addr string, config *Config) error {
+	server, err := NewServer(config)
+	if err != nil {
+		return err
+	}
+
+	// Many requests may run at once, so bound model calls and fetches, and
+	// keep their spinners off the server's log
+	SetConcurrencyLimits(config.LLMConcurrency, config.FetchConcurrency)
+	defer SuppressSpinners()()
+
+	// Summaries stream for as long as the model takes, so only the write
+	// timeout is generous, matching the LLM client timeout
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      10 * time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	fmt.Fprintf(os.Stderr, "🚀 %s API listening on %s (model %s)\n", appName, addr, config.DefaultModel)
+	return httpServer.ListenAndServe()
+}
+
+func (s *Server) handleSummarizeURL(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeSummarizeRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.URL == "" || !IsValidURL(req.URL) {
+		writeError(w, http.StatusBadRequest, "a valid url is required")
+		return
+	}
+
+	s.runSummary(w, r, req, req.URL, "", func(stream StreamFunc, stats *RunStats) (string, string, string, error) {
+		return ProcessURL(req.URL, s.config, req.Length, req.Markdown, req.Search, "", stream, stats)
+	})
+}
+
+func (s *Server) handleSummarizeText(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeSummarizeRequest(w, r)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		writeError(w, http.StatusBadRequest, "text is required")
+		return
+	}
+
+	source := &TextSource{Text: req.Text, Title: req.Title}
+	s.runSummary(w, r, req, "", "", func(stream StreamFunc, stats *RunStats) (string, string, string, error) {
+		return ProcessSource(source, s.config, req.Length, req.Markdown, req.Search, "", stream, stats)
+	})
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeSummarizeRequest(w, r)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	s.runSummary(w, r, req, "", req.Query, func(stream StreamFunc, stats *RunStats) (string, string, string, error) {
+		return ProcessSearchQuery(req.Query, s.config, req.Length, req.Markdown, "", stream, stats)
+	})
+}
+
+func (s *Server) handleOutline(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeSummarizeRequest(w, r)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(req.Summary) == "" {
+		writeError(w, http.StatusBadRequest, "summary is required")
+		return
+	}
+
+	stats := NewRunStats()
+	events := newSSEWriter(w, r, req.Stream)
+
+	outline, err := GenerateOutline(req.Summary, s.config, req.Markdown, "", events.TokenFunc(), stats)
+	if err != nil {
+		events.Error(http.StatusBadGateway, err)
+		return
+	}
+
+	report := NewSummaryReport(stats, s.config, req.Title, "", "", req.Length, req.Summary, outline)
+	events.Done(report)
+}
+
+// runSummary executes a summarization and replies with a SummaryReport,
+// either as a single JSON response or as a Server-Sent Events stream
+func (s *Server) runSummary(w http.ResponseWriter, r *http.Request, req summarizeRequest, sourceURL, query string, process func(StreamFunc, *RunStats) (string, string, string, error)) {
+	stats := NewRunStats()
+	events := newSSEWriter(w, r, req.Stream)
+
+	// When an outline is requested only the outline is streamed
+	summaryStream := events.TokenFunc()
+	if req.Outline {
+		summaryStream = nil
+	}
+
+	summary, _, title, err := process(summaryStream, stats)
+	if err != nil {
+		events.Error(http.StatusBadGateway, err)
+		return
+	}
+
+	var outline string
+	if req.Outline {
+		outline, err = GenerateOutline(summary, s.config, req.Markdown, "", events.TokenFunc(), stats)
+		if err != nil {
+			events.Error(http.StatusBadGateway, err)
+			return
+		}
+	}
+
+	events.Done(NewSummaryReport(stats, s.config, title, sourceURL, query, req.Length, summary, outline))
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	infos := make([]sessionInfo, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		infos = append(infos, newSessionInfo(session))
+	}
+	s.mu.Unlock()
+
+	saved, err := s.sessionManager.ListSessions()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	inMemory := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		inMemory[info.ID] = true
+	}
+	for _, session := range saved {
+		if !inMemory[session.ID] {
+			infos = append(infos, newSessionInfo(session))
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].LastAccessedAt.After(infos[j].LastAccessedAt)
+	})
+
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeSummarizeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var summary, content, title string
+	var err error
+	switch {
+	case req.URL != "":
+		summary, content, title, err = ProcessURL(req.URL, s.config, req.Length, req.Markdown, req.Search, "", nil, nil)
+	case req.Text != "":
+		summary, content, title, err = ProcessSource(&TextSource{Text: req.Text, Title: req.Title}, s.config, req.Length, req.Markdown, req.Search, "", nil, nil)
+	case req.Query != "":
+		summary, content, title, err = ProcessSearchQuery(req.Query, s.config, req.Length, req.Markdown, "", nil, nil)
+	default:
+		writeError(w, http.StatusBadRequest, "one of url, text or query is required")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	// Requests can arrive within the same second, so IDs use nanoseconds
+	session := s.sessionManager.NewSession(title, summary, req.Search)
+	session.ID = fmt.Sprintf("api_%d", time.Now().UnixNano())
+	session.URL = req.URL
+	session.Query = req.Query
+	session.ContextContent = content
+
+	s.mu.Lock()
+	s.sessions[session.ID] = session
+	s.persistSession(session)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, newSessionInfo(session))
+}
+
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	session, err := s.findSession(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	info := newSessionInfo(session)
+	messages := slices.Clone(session.Messages)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, struct {
+		sessionInfo
+		Messages []api.Message `json:"messages"`
+	}{info, messages})
+}
+
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	// Wait for a question in progress, which would save the session again
+	unlock := s.lockSession(id)
+	defer unlock()
+
+	s.mu.Lock()
+	_, inMemory := s.sessions[id]
+	s.mu.Unlock()
+	s.forgetSession(id)
+
+	if s.sessionManager.SessionExists(id) {
+		if err := s.sessionManager.DeleteSession(id); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else if !inMemory {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleAsk(w http.ResponseWriter, r *http.Request) {
+	var req askRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Question) == "" {
+		writeError(w, http.StatusBadRequest, "question is required")
+		return
+	}
+
+	// Concurrent questions to one session would answer from the same history
+	// and lose each other's messages, so they run one at a time
+	id := r.PathValue("id")
+	unlock := s.lockSession(id)
+	defer unlock()
+
+	session, err := s.findSession(id)
+	if err != nil {
+		s.forgetSession(id)
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	config, searchManager := s.sessionConfig(session)
+
+	// The request context stops generation when the client goes away
+	events := newSSEWriter(w, r, req.Stream)
+	answer, err := generateEnhancedResponse(r.Context(), req.Question, session, config, s.provider, searchManager, s.cacheManager, session.SearchEnabled, events.TokenFunc())
+	if err != nil {
+		events.Error(http.StatusBadGateway, err)
+		return
+	}
+
+	// Readers of the session hold s.mu rather than the session lock
+	s.mu.Lock()
+	s.sessionManager.AddMessage(session, "user", req.Question)
+	s.sessionManager.AddMessage(session, "assistant", answer)
+	s.persistSession(session)
+	s.mu.Unlock()
+
+	events.Done(map[string]string{"session_id": session.ID, "answer": answer})
+}
+
+// lockSession takes the lock of one session and returns its release function
+func (s *Server) lockSession(id string) func() {
+	s.mu.Lock()
+	lock, ok := s.sessionLocks[id]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.sessionLocks[id] = lock
+	}
+	s.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// forgetSession drops the locks and index a deleted or unknown session left
+// in memory, so a long-running server doesn't accumulate them
+func (s *Server) forgetSession(id string) {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	delete(s.sessionLocks, id)
+	s.mu.Unlock()
+	forgetIndex(id)
+}
+
+// sessionConfig returns the configuration to answer a session's questions
+// with: the server's, with the session's own profile applied over it
+func (s *Server) sessionConfig(session *SessionData) (*Config, *SearchManager) {
+	if session.Profile == "" || session.Profile == s.config.ActiveProfile {
+		return s.config, s.searchManager
+	}
+
+	config := *s.config
+	if err := config.ApplyProfile(session.Profile); err != nil {
+		DebugLog(s.config, "Answering session %s without its profile: %v", session.ID, err)
+		return s.config, s.searchManager
+	}
+	return &config, NewSearchManager(&config)
+}
+
+// persistSession saves a session to disk when session persistence is enabled
+func (s *Server) persistSession(session *SessionData) {
+	if !s.config.SessionPersist {
+		return
+	}
+	if err := s.sessionManager.SaveSession(session); err != nil {
+		DebugLog(s.config, "Failed to persist API session %s: %v", session.ID, err)
+	}
+}
+
+// findSession looks a session up in memory first, then on disk
+func (s *Server) findSession(id string) (*SessionData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[id]; ok {
+		return session, nil
+	}
+
+	session, err := s.sessionManager.LoadSession(id)
+	if err != nil {
+		return nil, fmt.Errorf("session not found: %s", id)
+	}
+	s.sessions[id] = session
+	return session, nil
+}
+
+// decodeSummarizeRequest parses the request body and fills in defaults
+func (s *Server) decodeSummarizeRequest(w http.ResponseWriter, r *http.Request) (summarizeRequest, bool) {
+	var req summarizeRequest
+	if !decodeBody(w, r, &req) {
+		return req, false
+	}
+
+	if req.Length == "" {
+		req.Length = s.config.DefaultLength
+	}
+	if _, ok := lengthMap[req.Length]; !ok {
+		writeError(w, http.StatusBadRequest, "length must be one of short, medium, long, detailed")
+		return req, false
+	}
+
+	return req, true
+}
+
+// decodeBody parses a JSON request body, replying with an error if it is
+// invalid or exceeds maxRequestBodyBytes
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+	} else {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+	}
+	return false
+}
+
+func newSessionInfo(session *SessionData) sessionInfo {
+	var sources []sessionSource
+	for _, doc := range session.Documents {
+		sources = append(sources, sessionSource{Title: doc.Title, URL: doc.URL})
+	}
+
+	return sessionInfo{
+		ID:             session.ID,
+		Title:          session.GetTitle(),
+		URL:            session.URL,
+		Query:          session.Query,
+		Summary:        session.InitialSummary,
+		SearchEnabled:  session.SearchEnabled,
+		MessageCount:   len(session.Messages),
+		CreatedAt:      session.CreatedAt,
+		LastAccessedAt: session.LastAccessedAt,
+		Sources:        sources,
+	}
+}
+
+// sseWriter sends either Server-Sent Events or a single JSON response,
+// depending on what the client asked for
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	enabled bool
+	started bool
+}
+
+// newSSEWriter enables streaming when requested in the body or via the Accept header
+func newSSEWriter(w http.ResponseWriter, r *http.Request, requested bool) *sseWriter {
+	sw := &sseWriter{w: w}
+	flusher, ok := w.(http.Flusher)
+	if ok && (requested || strings.Contains(r.Header.Get("Accept"), "text/event-stream")) {
+		sw.enabled = true
+		sw.flusher = flusher
+	}
+	return sw
+}
+
+// TokenFunc returns a StreamFunc emitting token events, or nil when not streaming
+func (sw *sseWriter) TokenFunc() StreamFunc {
+	if !sw.enabled {
+		return nil
+	}
+	return func(token string) {
+		sw.send("token", token)
+	}
+}
+
+// Done sends the final result
+func (sw *sseWriter) Done(result interface{}) {
+	if !sw.enabled {
+		writeJSON(sw.w, http.StatusOK, result)
+		return
+	}
+	sw.send("done", result)
+}
+
+// Error reports a failure, as an event once the stream has started
+func (sw *sseWriter) Error(status int, err error) {
+	if !sw.enabled {
+		writeError(sw.w, status, err.Error())
+		return
+	}
+	sw.send("error", map[string]string{"error": err.Error()})
+}
+
+func (sw *sseWriter) send(event string, payload interface{}) {
+	if !sw.started {
+		sw.w.Header().Set("Content-Type", "text/event-stream")
+		sw.w.Header().Set("Cache-Control", "no-cache")
+		sw.w.Header().Set("Connection", "keep-alive")
+		sw.w.WriteHeader(http.StatusOK)
+		sw.started = true
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	fmt.Fprintf(sw.w, "event: %s\ndata: %s\n\n", event, data)
+	sw.flusher.Flush()
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/ollama/ollama/api"
@@ -23,7 +24,38 @@ type SessionData struct {
 	LastAccessedAt time.Time     `json:"last_accessed_at"`
 	LastModified   time.Time     `json:"last_modified"`
 	SearchEnabled  bool          `json:"search_enabled"`
+	Profile        string        `json:"profile,omitempty"`
 	MessageCount   int           `json:"message_count"`
+
+	// Sessions over several sources (hvsum compare) keep each document
+	// here instead of in ContextContent
+	Documents []SessionDocument `json:"documents,omitempty"`
+}
+
+// SessionDocument is one source of a multi-document session
+type SessionDocument struct {
+	Title   string `json:"title"`
+	URL     string `json:"url,omitempty"`
+	Summary string `json:"summary"`
+	Content string `json:"content"`
+}
+
+// sourceHeaderFormat labels each document in the combined content of a
+// multi-document session, numbered like the citations in its summary
+const sourceHeaderFormat = "=== Source [%d]: %s (%s) ==="
+
+// Content returns the text questions are answered from: the single
+// document, or every document under its own source header
+func (session *SessionData) Content() string {
+	if len(session.Documents) == 0 {
+		return session.ContextContent
+	}
+
+	sections := make([]string, len(session.Documents))
+	for i, doc := range session.Documents {
+		sections[i] = fmt.Sprintf(sourceHeaderFormat, i+1, doc.Title, doc.URL) + "\n\n" + doc.Content
+	}
+	return strings.Join(sections, "\n\n")
 }
 
 // SessionManager handles session persistence and management
@@ -50,13 +82,25 @@ func (sm *SessionManager) CreateSession(summary, contextContent, title string, e
 		return nil, nil // Sessions disabled
 	}
 
-	sessionID := fmt.Sprintf("session_%d", time.Now().Unix())
+	session := sm.NewSession(title, summary, enableSearch)
+	session.ContextContent = contextContent
+
+	if err := sm.SaveSession(session); err != nil {
+		return nil, err
+	}
+
+	DebugLog(sm.config, "Created new session: %s", session.ID)
+	return session, nil
+}
 
-	session := &SessionData{
-		ID:             sessionID,
+// NewSession builds an unsaved session for questions about a summary,
+// seeded with the Q&A prompt of the active profile. Callers fill in the
+// content the questions are answered from.
+func (sm *SessionManager) NewSession(title, summary string, enableSearch bool) *SessionData {
+	return &SessionData{
+		ID:             fmt.Sprintf("session_%d", time.Now().Unix()),
 		Title:          title,
 		InitialSummary: summary,
-		ContextContent: contextContent,
 		Messages: []api.Message{
 			{
 				Role:    "system",
@@ -70,14 +114,16 @@ func (sm *SessionManager) CreateSession(summary, contextContent, title string, e
 		CreatedAt:      time.Now(),
 		LastAccessedAt: time.Now(),
 		SearchEnabled:  enableSearch,
+		Profile:        sm.config.ActiveProfile,
 	}
+}
 
-	if err := sm.SaveSession(session); err != nil {
-		return nil, err
-	}
-
-	DebugLog(sm.config, "Created new session: %s", sessionID)
-	return session, nil
+// NewMultiDocumentSession builds an unsaved session answering from several
+// documents, such as the sources of a comparison
+func (sm *SessionManager) NewMultiDocumentSession(title, summary string, documents []SessionDocument, enableSearch bool) *SessionData {
+	session := sm.NewSession(title, summary, enableSearch)
+	session.Documents = documents
+	return session
 }
 
 // SaveSession saves a session to disk
@@ -161,6 +207,8 @@ func (sm *SessionManager) ListSessions() ([]*SessionData, error) {
 // DeleteSession removes a session
 func (sm *SessionManager) DeleteSession(sessionID string) error {
 	sessionPath := filepath.Join(sm.sessionsDir, sessionID+".json")
+	os.Remove(sm.indexPath(sessionID)) // The document index may not exist
+	forgetIndex(sessionID)
 	return os.Remove(sessionPath)
 }
 
@@ -289,9 +337,15 @@ func (session *SessionData) PrintSessionInfo() {
 	}
 	fmt.Fprintf(os.Stderr, "Message count: %d\n", userMsgCount)
 	fmt.Fprintf(os.Stderr, "Search enabled: %t\n", session.SearchEnabled)
+	if session.Profile != "" {
+		fmt.Fprintf(os.Stderr, "Profile: %s\n", session.Profile)
+	}
 	if session.URL != "" {
 		fmt.Fprintf(os.Stderr, "Source URL: %s\n", session.URL)
 	}
+	for i, doc := range session.Documents {
+		fmt.Fprintf(os.Stderr, "Source [%d]: %s (%s)\n", i+1, doc.Title, doc.URL)
+	}
 	if session.Query != "" {
 		fmt.Fprintf(os.Stderr, "Search query: %s\n", session.Query)
 	}
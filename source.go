@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ContentSource is anything hvsum can extract a (content, title) pair from
+type ContentSource interface {
+	Extract() (string, string, error)
+	Location() string // URL, file:// URL, "-" for stdin or "text" for inline text
+	IsRemote() bool
+}
+
+// extensionTypes covers document types missing from Go's built-in MIME table
+var extensionTypes = map[string]string{
+	".md":       "text/markdown",
+	".markdown": "text/markdown",
+	".txt":      "text/plain",
+	".text":     "text/plain",
+	".log":      "text/plain",
+	".html":     "text/html",
+	".htm":      "text/html",
+}
+
+// DetectSource determines the kind of input: stdin ("-"), a file:// URL,
+// an existing local file or a web URL. It returns nil for search queries.
+func DetectSource(input string, config *Config) ContentSource {
+	if input == "-" {
+		return &StdinSource{}
+	}
+
+	if strings.HasPrefix(input, "file://") {
+		if parsedURL, err := url.Parse(input); err == nil {
+			return &FileSource{Path: parsedURL.Path}
+		}
+	}
+
+	if info, err := os.Stat(input); err == nil && !info.IsDir() {
+		return &FileSource{Path: input}
+	}
+
+	if IsValidURL(input) {
+		return &URLSource{URL: input, Config: config}
+	}
+
+	return nil
+}
+
+// URLSource fetches content over HTTP
+type URLSource struct {
+	URL    string
+	Config *Config
+
+	fromCache bool // The last Extract was served by the page cache
+}
+
+func (s *URLSource) Extract() (string, string, error) {
+	content, title, fromCache, err := fetchWebContent(s.URL, s.Config)
+	s.fromCache = fromCache
+	return content, title, err
+}
+
+func (s *URLSource) Location() string {
+	return s.URL
+}
+
+func (s *URLSource) IsRemote() bool {
+	return true
+}
+
+// FileSource reads a local document
+type FileSource struct {
+	Path string
+}
+
+func (s *FileSource) Extract() (string, string, error) {
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxContentBytes))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read file: %v", err)
+	}
+
+	absPath, _ := filepath.Abs(s.Path)
+	fileURL := &url.URL{Scheme: "file", Path: absPath}
+	return ExtractContent(data, mimeTypeForPath(s.Path), fileURL, filepath.Base(s.Path))
+}
+
+func (s *FileSource) Location() string {
+	absPath, err := filepath.Abs(s.Path)
+	if err != nil {
+		absPath = s.Path
+	}
+	return "file://" + absPath
+}
+
+func (s *FileSource) IsRemote() bool {
+	return false
+}
+
+// StdinSource reads piped input
+type StdinSource struct{}
+
+func (s *StdinSource) Extract() (string, string, error) {
+	data, err := io.ReadAll(io.LimitReader(os.Stdin, maxContentBytes))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read stdin: %v", err)
+	}
+	return ExtractContent(data, "", nil, "Standard Input")
+}
+
+func (s *StdinSource) Location() string {
+	return "-"
+}
+
+func (s *StdinSource) IsRemote() bool {
+	return false
+}
+
+// TextSource wraps text supplied directly, e.g. through the API server
+type TextSource struct {
+	Text  string
+	Title string
+}
+
+func (s *TextSource) Extract() (string, string, error) {
+	title := s.Title
+	if title == "" {
+		title = "Text Summary"
+	}
+	return extractPlainText([]byte(s.Text), title)
+}
+
+func (s *TextSource) Location() string {
+	return "text"
+}
+
+func (s *TextSource) IsRemote() bool {
+	return false
+}
+
+// mimeTypeForPath guesses a MIME type from the file extension; an empty
+// result lets ExtractContent sniff the content instead
+func mimeTypeForPath(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if mimeType, ok := extensionTypes[ext]; ok {
+		return mimeType
+	}
+	return mime.TypeByExtension(ext)
+}
@@ -3,12 +3,28 @@ package main
 import (
 	"fmt"
 	"os"
+	"sync/atomic"
 	"time"
 )
 
+// spinnerSuppressors counts the callers that run several summaries at once,
+// whose spinners would draw over each other on the same line
+var spinnerSuppressors atomic.Int32
+
+// SuppressSpinners turns StartSpinner into a no-op until restore is called
+func SuppressSpinners() (restore func()) {
+	spinnerSuppressors.Add(1)
+	return func() {
+		spinnerSuppressors.Add(-1)
+	}
+}
+
 // StartSpinner starts a more robust CLI spinner that properly clears the line.
 func StartSpinner(message string) chan struct{} {
 	stop := make(chan struct{})
+	if spinnerSuppressors.Load() > 0 {
+		return stop
+	}
 	go func() {
 		spinner := `⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏`
 		i := 0
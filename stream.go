@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/glamour"
+	"golang.org/x/term"
+)
+
+// StreamRenderer prints model output progressively as tokens arrive.
+// Plain text is written as-is; markdown on a terminal is re-rendered in place,
+// committing finished blocks so only the block in progress is redrawn.
+type StreamRenderer struct {
+	out         io.Writer
+	useMarkdown bool
+	renderer    *glamour.TermRenderer
+
+	mu         sync.Mutex
+	full       strings.Builder
+	pending    string
+	tailLines  int
+	lastRender time.Time
+	started    bool
+	beforeFunc func()
+}
+
+// renderInterval throttles how often the in-progress markdown block is redrawn
+const renderInterval = 120 * time.Millisecond
+
+// NewStreamRenderer creates a renderer writing to stdout. Markdown is only
+// rendered incrementally when stdout is a terminal.
+func NewStreamRenderer(useMarkdown bool) *StreamRenderer {
+	sr := &StreamRenderer{out: os.Stdout}
+
+	if useMarkdown && IsTerminal(os.Stdout) {
+		r, err := glamour.NewTermRenderer(
+			glamour.WithAutoStyle(),
+			glamour.WithWordWrap(renderWidth()),
+		)
+		if err == nil {
+			sr.useMarkdown = true
+			sr.renderer = r
+		}
+	}
+
+	return sr
+}
+
+// BeforeFirstToken registers a hook run once before any output is written,
+// used to stop progress indicators sharing the terminal
+func (sr *StreamRenderer) BeforeFirstToken(fn func()) {
+	sr.beforeFunc = fn
+}
+
+// Write handles a single streamed token
+func (sr *StreamRenderer) Write(token string) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if !sr.started {
+		sr.started = true
+		if sr.beforeFunc != nil {
+			sr.beforeFunc()
+		}
+	}
+	sr.full.WriteString(token)
+
+	if !sr.useMarkdown {
+		fmt.Fprint(sr.out, token)
+		return
+	}
+
+	sr.pending += token
+
+	// Commit everything up to the last complete block
+	if boundary := lastBlockBoundary(sr.pending); boundary > 0 {
+		sr.clearTail()
+		sr.printMarkdown(sr.pending[:boundary])
+		sr.pending = sr.pending[boundary:]
+		sr.tailLines = 0
+	}
+
+	if time.Since(sr.lastRender) >= renderInterval {
+		sr.redrawTail()
+	}
+}
+
+// Finish flushes any remaining output and reports whether anything was streamed
+func (sr *StreamRenderer) Finish() bool {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if !sr.started {
+		return false
+	}
+
+	if sr.useMarkdown {
+		sr.clearTail()
+		sr.printMarkdown(sr.pending)
+		sr.pending = ""
+		sr.tailLines = 0
+	} else if !strings.HasSuffix(sr.full.String(), "\n") {
+		fmt.Fprintln(sr.out)
+	}
+
+	return true
+}
+
+// Text returns everything streamed so far
+func (sr *StreamRenderer) Text() string {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return sr.full.String()
+}
+
+// redrawTail re-renders the unfinished block at the bottom of the output
+func (sr *StreamRenderer) redrawTail() {
+	sr.clearTail()
+	sr.tailLines = sr.printMarkdown(sr.pending)
+	sr.lastRender = time.Now()
+}
+
+// clearTail moves the cursor back over the previously drawn tail and erases it
+func (sr *StreamRenderer) clearTail() {
+	if sr.tailLines > 0 {
+		fmt.Fprintf(sr.out, "\033[%dA\r\033[J", sr.tailLines)
+	}
+}
+
+// printMarkdown renders and prints markdown, returning the number of lines written
+func (sr *StreamRenderer) printMarkdown(markdown string) int {
+	if strings.TrimSpace(markdown) == "" {
+		return 0
+	}
+
+	rendered, err := sr.renderer.Render(markdown)
+	if err != nil {
+		rendered = markdown
+	}
+	rendered = strings.TrimRight(rendered, "\n") + "\n"
+
+	fmt.Fprint(sr.out, rendered)
+	return strings.Count(rendered, "\n")
+}
+
+// lastBlockBoundary returns the offset just past the last blank line that is
+// not inside a fenced code block, or 0 if there is none
+func lastBlockBoundary(text string) int {
+	boundary := 0
+	inFence := false
+	offset := 0
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		offset += len(line)
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		if !inFence && trimmed == "" && strings.HasSuffix(line, "\n") {
+			boundary = offset
+		}
+	}
+
+	return boundary
+}
+
+// prefixGate holds back streamed tokens until it is clear the response does
+// not start with a control prefix (such as SEARCH_NEEDED:), which is never shown
+type prefixGate struct {
+	prefix     string
+	next       StreamFunc
+	buffer     strings.Builder
+	decided    bool
+	suppressed bool
+}
+
+func newPrefixGate(prefix string, next StreamFunc) *prefixGate {
+	return &prefixGate{prefix: prefix, next: next}
+}
+
+// Write passes a token through once the prefix has been ruled out
+func (g *prefixGate) Write(token string) {
+	if g.decided {
+		if !g.suppressed {
+			g.next(token)
+		}
+		return
+	}
+
+	g.buffer.WriteString(token)
+	buffered := strings.TrimLeft(g.buffer.String(), " \t\n")
+
+	switch {
+	case strings.HasPrefix(buffered, g.prefix):
+		g.decided = true
+		g.suppressed = true
+	case !strings.HasPrefix(g.prefix, buffered):
+		g.decided = true
+		g.next(g.buffer.String())
+	}
+}
+
+// Flush releases buffered text for responses shorter than the prefix
+func (g *prefixGate) Flush() {
+	if !g.decided && g.buffer.Len() > 0 && !strings.HasPrefix(strings.TrimSpace(g.buffer.String()), g.prefix) {
+		g.next(g.buffer.String())
+	}
+	g.decided = true
+}
+
+// IsTerminal reports whether the file is attached to a terminal
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// renderWidth returns the word wrap width for rendered markdown
+func renderWidth() int {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || width > 100 {
+		return 100
+	}
+	return width
+}
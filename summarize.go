@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"os"
 	"strings"
-
-	"github.com/ollama/ollama/api"
+	"sync"
+	"time"
 )
 
+// pageCitationInstruction asks the model to cite pages of paged documents such as PDFs
+const pageCitationInstruction = `The content is split into pages marked "--- Page N ---". Cite the page number for each key point, e.g. (p. 4).`
+
 // Length definitions for precise length control
 var lengthMap = map[string]string{
 	"short":    "3-5 concise sentences maximum. Focus on the most essential information only.",
@@ -18,52 +21,89 @@ var lengthMap = map[string]string{
 }
 
 // ProcessURL handles URL-based summarization with the new two-stage approach
-func ProcessURL(urlStr string, config *Config, length string, useMarkdown, enableSearch bool, sessionID string) (string, string, string, error) {
-	fmt.Fprintf(os.Stderr, "🌐 Fetching content from: %s\n", urlStr)
+func ProcessURL(urlStr string, config *Config, length string, useMarkdown, enableSearch bool, sessionID string, stream StreamFunc, stats *RunStats) (string, string, string, error) {
+	return ProcessSource(&URLSource{URL: urlStr, Config: config}, config, length, useMarkdown, enableSearch, sessionID, stream, stats)
+}
+
+// ProcessSource summarizes any content source (URL, local file or stdin)
+func ProcessSource(source ContentSource, config *Config, length string, useMarkdown, enableSearch bool, sessionID string, stream StreamFunc, stats *RunStats) (string, string, string, error) {
+	location := source.Location()
+	if source.IsRemote() {
+		fmt.Fprintf(os.Stderr, "🌐 Fetching content from: %s\n", location)
+	} else {
+		fmt.Fprintf(os.Stderr, "📄 Reading content from: %s\n", location)
+	}
 
 	// Initialize cache manager
 	cacheManager := NewCacheManager(config)
 
+	// Remote pages are revalidated with a conditional request, which is
+	// cheap when they haven't changed and drops the summaries of pages that
+	// have, so extraction always comes before the cache lookup. A failed
+	// refetch falls back to the cached page, so its summary stays usable.
+	extractStart := time.Now()
+	content, title, err := source.Extract()
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to extract content: %v", err)
+	}
+	urlSource, isURL := source.(*URLSource)
+	stats.Record("extract", extractStart, isURL && urlSource.fromCache)
+
+	// Remote sources are cached by URL; local files and stdin can change
+	// under the same name, so they are cached by their content instead
+	cacheInput := content
+	if source.IsRemote() {
+		cacheInput = location
+	}
+
 	// Check cache first for final result
-	cacheKey := cacheManager.GetCacheKey(fmt.Sprintf("url:%s:%s:%t:%t", urlStr, length, useMarkdown, enableSearch))
+	summaryStart := time.Now()
+	cacheKey := cacheManager.GetCacheKey("url", cacheInput, length, useMarkdown, enableSearch, pipelineFingerprint(config))
 	var cachedSummary string
 	if cacheManager.Get(cacheKey, &cachedSummary) {
 		DebugLog(config, "Cache hit for URL summary")
-		return cachedSummary, cachedSummary, "Cached Summary", nil
-	}
-
-	// Extract content from URL
-	content, title, err := ExtractWebContent(urlStr)
-	if err != nil {
-		return "", "", "", fmt.Errorf("failed to extract content: %v", err)
+		stats.Record("summary", summaryStart, true)
+		return cachedSummary, content, title, nil
 	}
 
-	DebugLog(config, "Extracted %d characters from URL", len(content))
+	DebugLog(config, "Extracted %d characters from %s", len(content), location)
 	DebugLog(config, "Page title: %s", title)
 
 	// Two-stage summarization process
-	finalSummary, err := generateTwoStageSummary(config, length, useMarkdown, enableSearch, content, title, urlStr, sessionID)
+	sourceURL := location
+	if !strings.Contains(sourceURL, "://") && !IsValidURL(sourceURL) {
+		sourceURL = ""
+	}
+	finalSummary, err := generateTwoStageSummary(config, length, useMarkdown, enableSearch, content, title, sourceURL, sessionID, stream, stats)
 	if err != nil {
 		return "", "", "", err
 	}
+	stats.Record("summary", summaryStart, false)
 
 	// Cache the final result
 	cacheManager.Set(cacheKey, finalSummary, sessionID)
+	if source.IsRemote() {
+		NewPageCache(config).AddDependent(location, cacheKey)
+	}
+
+	recordInKnowledgeBase(config, sourceURL, "", title, finalSummary, content)
 	return finalSummary, content, title, nil
 }
 
 // ProcessSearchQuery handles search-only summarization with two-stage approach
-func ProcessSearchQuery(query string, config *Config, length string, useMarkdown bool, sessionID string) (string, string, string, error) {
+func ProcessSearchQuery(query string, config *Config, length string, useMarkdown bool, sessionID string, stream StreamFunc, stats *RunStats) (string, string, string, error) {
 	fmt.Fprintf(os.Stderr, "🔍 Performing web search for: %s\n", query)
 
 	// Initialize cache manager
 	cacheManager := NewCacheManager(config)
 
 	// Check cache first
-	cacheKey := cacheManager.GetCacheKey(fmt.Sprintf("search:%s:%s:%t", query, length, useMarkdown))
+	summaryStart := time.Now()
+	cacheKey := cacheManager.GetCacheKey("search", query, length, useMarkdown, pipelineFingerprint(config))
 	var cachedSummary string
 	if cacheManager.Get(cacheKey, &cachedSummary) {
 		DebugLog(config, "Cache hit for search summary")
+		stats.Record("summary", summaryStart, true)
 		return cachedSummary, cachedSummary, query, nil
 	}
 
@@ -71,7 +111,7 @@ func ProcessSearchQuery(query string, config *Config, length string, useMarkdown
 	searchManager := NewSearchManager(config)
 
 	// Generate fewer related search queries for better performance
-	relatedQueries, err := generateSearchQueries(config, query, "provide comprehensive information about this topic", sessionID)
+	relatedQueries, err := generateSearchQueries(config, query, "provide comprehensive information about this topic", sessionID, stats)
 	if err != nil {
 		DebugLog(config, "Failed to generate related queries: %v", err)
 		relatedQueries = []string{}
@@ -89,89 +129,273 @@ func ProcessSearchQuery(query string, config *Config, length string, useMarkdown
 
 	// Perform parallel searches with fewer results per query
 	fmt.Fprintf(os.Stderr, "🚀 Performing parallel web searches...\n")
+	searchStart := time.Now()
 	searchResults := searchManager.PerformParallelSearches(allQueries, 2, sessionID)
+	stats.Record("search", searchStart, false)
+	stats.AddSearchResults(searchResults)
 
 	if len(searchResults) == 0 {
+		if config.Offline {
+			return "", "", "", fmt.Errorf("no cached search results for query '%s': %w", query, errOffline)
+		}
 		return "", "", "", fmt.Errorf("no search results found for query: %s", query)
 	}
 
 	DebugLog(config, "Found %d total search results", len(searchResults))
 
 	// Generate summary from search results using two-stage approach
-	finalSummary, err := generateSearchOnlySummaryTwoStage(config, length, useMarkdown, query, searchResults, sessionID)
+	finalSummary, err := generateSearchOnlySummaryTwoStage(config, length, useMarkdown, query, searchResults, sessionID, stream, stats)
 	if err != nil {
 		return "", "", "", err
 	}
+	stats.Record("summary", summaryStart, false)
 
 	// Cache the result
 	cacheManager.Set(cacheKey, finalSummary, sessionID)
+
+	// The search results are what later questions can be answered from
+	recordInKnowledgeBase(config, "", query, query, finalSummary, strings.TrimSpace(FormatSearchResults(searchResults)))
 	return finalSummary, finalSummary, query, nil
 }
 
 // generateTwoStageSummary implements the two-stage summarization process
-func generateTwoStageSummary(config *Config, length string, useMarkdown, enableSearch bool, content, title, sourceURL string, sessionID string) (string, error) {
+func generateTwoStageSummary(config *Config, length string, useMarkdown, enableSearch bool, content, title, sourceURL string, sessionID string, stream StreamFunc, stats *RunStats) (string, error) {
 	DebugLog(config, "Starting two-stage summarization process")
 
+	var searchResults []SearchResult
+	if enableSearch {
+		searchResults = gatherSearchContext(config, content, sessionID, stats)
+	}
+	citedStream, flushCitations := citationStream(stream, searchResults)
+
+	// Only the final stage is streamed to the terminal
+	stageOneStream := citedStream
+	if length != "detailed" {
+		stageOneStream = nil
+	}
+
 	// Stage 1: Generate detailed summary with all content
-	detailedSummary, err := generateDetailedSummary(config, useMarkdown, enableSearch, content, title, sourceURL, sessionID)
+	detailedSummary, err := generateDetailedSummary(config, useMarkdown, content, title, sourceURL, searchResults, sessionID, stageOneStream, stats)
 	if err != nil {
 		return "", fmt.Errorf("stage 1 failed: %v", err)
 	}
 
 	// Stage 2: Apply length constraint if not already detailed
-	if length == "detailed" {
-		return detailedSummary, nil
+	finalSummary := detailedSummary
+	if length != "detailed" {
+		finalSummary, err = applyLengthConstraint(config, useMarkdown, detailedSummary, length, sessionID, citedStream, stats)
+		if err != nil {
+			return "", fmt.Errorf("stage 2 failed: %v", err)
+		}
 	}
 
-	finalSummary, err := applyLengthConstraint(config, useMarkdown, detailedSummary, length, sessionID)
+	flushCitations()
+	return attachSources(finalSummary, searchResults, useMarkdown, stream), nil
+}
+
+// gatherSearchContext searches the web for information related to the content
+func gatherSearchContext(config *Config, content, sessionID string, stats *RunStats) []SearchResult {
+	fmt.Fprintf(os.Stderr, "🔍 Enhancing summary with web search...\n")
+	searchManager := NewSearchManager(config)
+	queries, err := generateSearchQueries(config, content[:Min(1000, len(content))], "enhance this content summary", sessionID, stats)
 	if err != nil {
-		return "", fmt.Errorf("stage 2 failed: %v", err)
+		DebugLog(config, "Search query generation failed: %v", err)
+		return nil
 	}
 
-	return finalSummary, nil
+	fmt.Fprintf(os.Stderr, "🚀 Performing parallel searches...\n")
+	searchStart := time.Now()
+	searchResults := searchManager.PerformParallelSearches(queries, 2, sessionID)
+	stats.Record("search", searchStart, false)
+	stats.AddSearchResults(searchResults)
+	if config.Offline && len(searchResults) == 0 {
+		fmt.Fprintf(os.Stderr, "📴 Offline: no cached search results, summarizing the content alone\n")
+	}
+	DebugLog(config, "Enhanced with %d search results", len(searchResults))
+	return searchResults
+}
+
+// attachSources validates the summary's citations and appends the sources list,
+// streaming the list after the already streamed summary
+func attachSources(summary string, searchResults []SearchResult, useMarkdown bool, stream StreamFunc) string {
+	body, sources := ApplyCitations(summary, searchResults, useMarkdown)
+	if sources != "" && stream != nil {
+		stream(sources)
+	}
+	return body + sources
 }
 
 // generateDetailedSummary creates a comprehensive summary with all available information
-func generateDetailedSummary(config *Config, useMarkdown, enableSearch bool, content, title, sourceURL string, sessionID string) (string, error) {
+func generateDetailedSummary(config *Config, useMarkdown bool, content, title, sourceURL string, searchResults []SearchResult, sessionID string, stream StreamFunc, stats *RunStats) (string, error) {
 	systemPrompt := config.SystemPrompts.Summary
 	if useMarkdown {
 		systemPrompt += "\n\n" + config.SystemPrompts.Markdown
 	}
 
-	var searchResults []SearchResult
-	if enableSearch {
-		fmt.Fprintf(os.Stderr, "🔍 Enhancing summary with web search...\n")
-		searchManager := NewSearchManager(config)
-		queries, err := generateSearchQueries(config, content[:Min(1000, len(content))], "enhance this content summary", sessionID)
-		if err != nil {
-			DebugLog(config, "Search query generation failed: %v", err)
-		} else {
-			fmt.Fprintf(os.Stderr, "🚀 Performing parallel searches...\n")
-			searchResults = searchManager.PerformParallelSearches(queries, 2, sessionID)
-			DebugLog(config, "Enhanced with %d search results", len(searchResults))
-		}
+	// Documents larger than the model's context are summarized chunk by chunk
+	model, _ := config.ModelFor(TaskSummary)
+	if EstimateTokens(content) > config.ChunkTokens(model) {
+		return generateMapReduceSummary(config, useMarkdown, content, title, sourceURL, searchResults, sessionID, stream, stats)
 	}
 
 	// Build detailed summary prompt
 	userPrompt := buildDetailedPrompt(content, title, sourceURL, searchResults)
 
-	fmt.Fprintf(os.Stderr, "🤖 Generating comprehensive summary with %s...\n", config.DefaultModel)
+	fmt.Fprintf(os.Stderr, "🤖 Generating comprehensive summary with %s...\n", model)
 
 	var spinnerStop chan struct{}
-	if useMarkdown {
+	if useMarkdown && stream == nil {
 		spinnerStop = StartSpinner("Generating detailed summary")
 	}
 
-	summary, err := callOllama(config, systemPrompt, userPrompt)
+	generateStart := time.Now()
+	summary, err := callLLMStream(config, TaskSummary, systemPrompt, userPrompt, stream)
 	if spinnerStop != nil {
 		close(spinnerStop)
 	}
+	stats.Record("detailed_summary", generateStart, false)
 
 	return summary, err
 }
 
+// generateMapReduceSummary summarizes each chunk of a long document concurrently
+// and merges the partial summaries into a single detailed summary
+func generateMapReduceSummary(config *Config, useMarkdown bool, content, title, sourceURL string, searchResults []SearchResult, sessionID string, stream StreamFunc, stats *RunStats) (string, error) {
+	model, _ := config.ModelFor(TaskSummary)
+	chunkTokens := config.ChunkTokens(model)
+	chunks := ChunkText(content, chunkTokens, chunkTokens/10)
+	DebugLog(config, "Split %d characters into %d chunks of up to %d tokens", len(content), len(chunks), chunkTokens)
+
+	fmt.Fprintf(os.Stderr, "📚 Long document, summarizing %d parts with %s...\n", len(chunks), model)
+	mapStart := time.Now()
+	partials, err := summarizeChunks(config, chunks, title, sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	// Collapse the partial summaries until they fit into a single reduce prompt
+	for len(partials) > 1 && EstimateTokens(strings.Join(partials, "\n\n")) > chunkTokens {
+		groups := ChunkText(strings.Join(partials, "\n\n"), chunkTokens, 0)
+		if len(groups) >= len(partials) {
+			break
+		}
+		DebugLog(config, "Collapsing %d partial summaries into %d groups", len(partials), len(groups))
+		partials, err = summarizeChunks(config, groups, title, sessionID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	systemPrompt := config.SystemPrompts.Summary
+	if useMarkdown {
+		systemPrompt += "\n\n" + config.SystemPrompts.Markdown
+	}
+	userPrompt := buildReducePrompt(partials, title, sourceURL, searchResults)
+
+	stats.Record("chunk_summaries", mapStart, false)
+	fmt.Fprintf(os.Stderr, "🧩 Merging %d partial summaries...\n", len(partials))
+
+	var spinnerStop chan struct{}
+	if useMarkdown && stream == nil {
+		spinnerStop = StartSpinner("Merging partial summaries")
+	}
+
+	reduceStart := time.Now()
+	summary, err := callLLMStream(config, TaskSummary, systemPrompt, userPrompt, stream)
+	if spinnerStop != nil {
+		close(spinnerStop)
+	}
+	stats.Record("detailed_summary", reduceStart, false)
+
+	return summary, err
+}
+
+// summarizeChunks runs the map step over all chunks with bounded concurrency,
+// returning the partial summaries in document order
+func summarizeChunks(config *Config, chunks []string, title string, sessionID string) ([]string, error) {
+	cacheManager := NewCacheManager(config)
+	partials := make([]string, len(chunks))
+	errs := make([]error, len(chunks))
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, Max(config.MaxParallelChunks, 1))
+
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(index int, text string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			userPrompt := fmt.Sprintf(`This is part %d of %d of a longer document titled "%s".
+
+Summarize this part thoroughly. Keep every key fact, name, number, argument and conclusion, since this summary will later be merged with the summaries of the other parts.
+
+Content:
+%s`, index+1, len(chunks), title, text)
+			if hasPageMarkers(text) {
+				userPrompt += "\n\n" + pageCitationInstruction
+			}
+
+			cacheKey := cacheManager.PromptCacheKey("chunk", TaskSummary, config.SystemPrompts.Summary, userPrompt)
+			var cachedPartial string
+			if cacheManager.Get(cacheKey, &cachedPartial) {
+				DebugLog(config, "Cache hit for chunk %d", index+1)
+				partials[index] = cachedPartial
+				return
+			}
+
+			partial, err := callLLM(config, TaskSummary, config.SystemPrompts.Summary, userPrompt)
+			if err != nil {
+				errs[index] = fmt.Errorf("part %d: %v", index+1, err)
+				return
+			}
+
+			partials[index] = partial
+			cacheManager.Set(cacheKey, partial, sessionID)
+		}(i, chunk)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("chunk summarization failed: %v", err)
+		}
+	}
+
+	return partials, nil
+}
+
+// buildReducePrompt creates the prompt that merges partial summaries
+func buildReducePrompt(partials []string, title, sourceURL string, searchResults []SearchResult) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf(`The following are summaries of consecutive parts of a long document. Merge them into one comprehensive, coherent summary of the whole document. Remove repetition between parts, keep all important facts and preserve the order of the original.
+
+Title: %s
+`, title))
+
+	for i, partial := range partials {
+		builder.WriteString(fmt.Sprintf("\n--- PART %d ---\n%s\n", i+1, partial))
+	}
+
+	builder.WriteString("\nKeep any page citations such as (p. 4) from the partial summaries.\n")
+
+	if len(searchResults) > 0 {
+		builder.WriteString(FormatSearchResults(searchResults))
+		builder.WriteString("\n\nUse both the partial summaries and the search results to create a comprehensive summary.")
+		builder.WriteString("\n\n" + citationInstruction)
+	}
+
+	if sourceURL != "" {
+		builder.WriteString(fmt.Sprintf("\n\nSource URL: %s", sourceURL))
+	}
+
+	return builder.String()
+}
+
 // applyLengthConstraint reduces a detailed summary to the requested length
-func applyLengthConstraint(config *Config, useMarkdown bool, detailedSummary, targetLength string, sessionID string) (string, error) {
+func applyLengthConstraint(config *Config, useMarkdown bool, detailedSummary, targetLength string, sessionID string, stream StreamFunc, stats *RunStats) (string, error) {
 	lengthInstruction, exists := lengthMap[targetLength]
 	if !exists {
 		lengthInstruction = lengthMap["medium"]
@@ -185,6 +409,7 @@ CRITICAL RULES:
 3. Maintain clarity and coherence
 4. Remove redundant or less important details
 5. Keep the same format and structure style
+6. Keep inline citations such as [1] or [2, 3] attached to the statements they support
 
 OUTPUT: Only the reduced summary, no meta-commentary.`, lengthInstruction)
 
@@ -195,49 +420,64 @@ OUTPUT: Only the reduced summary, no meta-commentary.`, lengthInstruction)
 	userPrompt := fmt.Sprintf("Reduce this detailed summary to the specified length:\n\n%s", detailedSummary)
 
 	// Use cache for length reductions
+	reduceStart := time.Now()
 	cacheManager := NewCacheManager(config)
-	cacheKey := cacheManager.GetCacheKey(fmt.Sprintf("reduce:%s:%s", detailedSummary[:Min(200, len(detailedSummary))], targetLength))
+	cacheKey := cacheManager.PromptCacheKey("reduce", TaskLength, systemPrompt, userPrompt)
 	var cachedReduction string
 	if cacheManager.Get(cacheKey, &cachedReduction) {
 		DebugLog(config, "Cache hit for length reduction")
+		stats.Record("length_reduction", reduceStart, true)
+		// Stream the cached reduction so it is rendered ahead of the sources
+		if stream != nil {
+			stream(cachedReduction)
+		}
 		return cachedReduction, nil
 	}
 
 	fmt.Fprintf(os.Stderr, "📝 Applying length constraint (%s)...\n", targetLength)
 
-	summary, err := callOllama(config, systemPrompt, userPrompt)
+	summary, err := callLLMStream(config, TaskLength, systemPrompt, userPrompt, stream)
 	if err != nil {
 		return "", err
 	}
 
 	// Cache the reduction
 	cacheManager.Set(cacheKey, summary, sessionID)
+	stats.Record("length_reduction", reduceStart, false)
 	return summary, nil
 }
 
 // generateSearchOnlySummaryTwoStage applies two-stage approach to search-only results
-func generateSearchOnlySummaryTwoStage(config *Config, length string, useMarkdown bool, query string, searchResults []SearchResult, sessionID string) (string, error) {
+func generateSearchOnlySummaryTwoStage(config *Config, length string, useMarkdown bool, query string, searchResults []SearchResult, sessionID string, stream StreamFunc, stats *RunStats) (string, error) {
+	citedStream, flushCitations := citationStream(stream, searchResults)
+
+	// Only the final stage is streamed to the terminal
+	stageOneStream := citedStream
+	if length != "detailed" {
+		stageOneStream = nil
+	}
+
 	// Stage 1: Generate detailed summary from all search results
-	detailedSummary, err := generateDetailedSearchSummary(config, useMarkdown, query, searchResults, sessionID)
+	detailedSummary, err := generateDetailedSearchSummary(config, useMarkdown, query, searchResults, sessionID, stageOneStream, stats)
 	if err != nil {
 		return "", fmt.Errorf("stage 1 failed: %v", err)
 	}
 
 	// Stage 2: Apply length constraint if needed
-	if length == "detailed" {
-		return detailedSummary, nil
-	}
-
-	finalSummary, err := applyLengthConstraint(config, useMarkdown, detailedSummary, length, sessionID)
-	if err != nil {
-		return "", fmt.Errorf("stage 2 failed: %v", err)
+	finalSummary := detailedSummary
+	if length != "detailed" {
+		finalSummary, err = applyLengthConstraint(config, useMarkdown, detailedSummary, length, sessionID, citedStream, stats)
+		if err != nil {
+			return "", fmt.Errorf("stage 2 failed: %v", err)
+		}
 	}
 
-	return finalSummary, nil
+	flushCitations()
+	return attachSources(finalSummary, searchResults, useMarkdown, stream), nil
 }
 
 // generateDetailedSearchSummary creates comprehensive summary from search results
-func generateDetailedSearchSummary(config *Config, useMarkdown bool, query string, searchResults []SearchResult, sessionID string) (string, error) {
+func generateDetailedSearchSummary(config *Config, useMarkdown bool, query string, searchResults []SearchResult, sessionID string, stream StreamFunc, stats *RunStats) (string, error) {
 	systemPrompt := config.SystemPrompts.SearchOnly
 	if useMarkdown {
 		systemPrompt += "\n\n" + config.SystemPrompts.Markdown
@@ -249,19 +489,24 @@ Based on the following search results, provide a detailed summary covering all r
 
 %s
 
-Create a thorough, well-structured summary that covers all important information from these search results.`, query, FormatSearchResults(searchResults))
+Create a thorough, well-structured summary that covers all important information from these search results.
+
+%s`, query, FormatSearchResults(searchResults), citationInstruction)
 
-	fmt.Fprintf(os.Stderr, "🤖 Generating comprehensive summary with %s...\n", config.DefaultModel)
+	model, _ := config.ModelFor(TaskSummary)
+	fmt.Fprintf(os.Stderr, "🤖 Generating comprehensive summary with %s...\n", model)
 
 	var spinnerStop chan struct{}
-	if useMarkdown {
+	if useMarkdown && stream == nil {
 		spinnerStop = StartSpinner("Generating detailed summary")
 	}
 
-	summary, err := callOllama(config, systemPrompt, userPrompt)
+	generateStart := time.Now()
+	summary, err := callLLMStream(config, TaskSummary, systemPrompt, userPrompt, stream)
 	if spinnerStop != nil {
 		close(spinnerStop)
 	}
+	stats.Record("detailed_summary", generateStart, false)
 
 	return summary, err
 }
@@ -274,9 +519,14 @@ Title: %s
 Content:
 %s`, title, content)
 
+	if hasPageMarkers(content) {
+		prompt += "\n\n" + pageCitationInstruction
+	}
+
 	if len(searchResults) > 0 {
 		prompt += FormatSearchResults(searchResults)
 		prompt += "\n\nUse both the webpage content and the search results to create a comprehensive summary."
+		prompt += "\n\n" + citationInstruction
 	}
 
 	if sourceURL != "" {
@@ -287,18 +537,9 @@ Content:
 }
 
 // generateSearchQueries uses AI to generate relevant search queries with caching
-func generateSearchQueries(config *Config, contextText, purpose string, sessionID string) ([]string, error) {
+func generateSearchQueries(config *Config, contextText, purpose string, sessionID string, stats *RunStats) ([]string, error) {
 	DebugLog(config, "Generating search queries for: %.100s...", contextText)
 
-	// Check cache first
-	cacheManager := NewCacheManager(config)
-	cacheKey := cacheManager.GetCacheKey(fmt.Sprintf("queries:%s:%s", contextText[:Min(200, len(contextText))], purpose))
-	var cachedQueries []string
-	if cacheManager.Get(cacheKey, &cachedQueries) {
-		DebugLog(config, "Cache hit for search queries")
-		return cachedQueries, nil
-	}
-
 	// Simplified prompt for faster processing
 	prompt := fmt.Sprintf(`Generate 2 specific search queries based on this context:
 
@@ -308,7 +549,18 @@ Purpose: %s
 
 Return only 2 queries, one per line:`, contextText[:Min(500, len(contextText))], purpose)
 
-	queries, err := callOllama(config, config.SystemPrompts.SearchQuery, prompt)
+	// Check cache first
+	queriesStart := time.Now()
+	cacheManager := NewCacheManager(config)
+	cacheKey := cacheManager.PromptCacheKey("queries", TaskSearchQuery, config.SystemPrompts.SearchQuery, prompt)
+	var cachedQueries []string
+	if cacheManager.Get(cacheKey, &cachedQueries) {
+		DebugLog(config, "Cache hit for search queries")
+		stats.Record("search_queries", queriesStart, true)
+		return cachedQueries, nil
+	}
+
+	queries, err := callLLM(config, TaskSearchQuery, config.SystemPrompts.SearchQuery, prompt)
 	if err != nil {
 		return nil, err
 	}
@@ -340,40 +592,34 @@ Return only 2 queries, one per line:`, contextText[:Min(500, len(contextText))],
 
 	// Cache the queries
 	cacheManager.Set(cacheKey, parsedQueries, sessionID)
+	stats.Record("search_queries", queriesStart, false)
 	DebugLog(config, "Generated %d search queries", len(parsedQueries))
 	return parsedQueries, nil
 }
 
-// callOllama makes a call to the Ollama API with better error handling
-func callOllama(config *Config, systemPrompt, userPrompt string) (string, error) {
-	client, err := api.ClientFromEnvironment()
-	if err != nil {
-		return "", fmt.Errorf("failed to connect to Ollama: %v", err)
-	}
+// callLLM makes a single completion call through the configured provider,
+// using the model and options configured for the task
+func callLLM(config *Config, task Task, systemPrompt, userPrompt string) (string, error) {
+	return callLLMStream(config, task, systemPrompt, userPrompt, nil)
+}
 
-	stream := false
-	req := &api.GenerateRequest{
-		Model:  config.DefaultModel,
-		System: systemPrompt,
-		Prompt: userPrompt,
-		Stream: &stream,
-		Options: map[string]interface{}{
-			"temperature": 0.1, // Lower temperature for more consistent summaries
-			"top_p":       0.9,
-		},
+// callLLMStream is callLLM with tokens forwarded to stream as they arrive
+func callLLMStream(config *Config, task Task, systemPrompt, userPrompt string, stream StreamFunc) (string, error) {
+	provider, err := NewLLMProvider(config)
+	if err != nil {
+		return "", err
 	}
 
-	var responseBuilder strings.Builder
-	err = client.Generate(context.Background(), req, func(resp api.GenerateResponse) error {
-		responseBuilder.WriteString(resp.Response)
-		return nil
-	})
+	release := acquireLLMSlot()
+	defer release()
 
+	model, options := config.ModelFor(task)
+	response, err := provider.Generate(context.Background(), model, systemPrompt, userPrompt, options, stream)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate response: %v", err)
 	}
 
-	response := strings.TrimSpace(responseBuilder.String())
+	response = strings.TrimSpace(response)
 	if response == "" {
 		return "", fmt.Errorf("received empty response from model")
 	}
@@ -382,20 +628,11 @@ func callOllama(config *Config, systemPrompt, userPrompt string) (string, error)
 }
 
 // GenerateOutline creates an outline from a summary with caching
-func GenerateOutline(summary string, config *Config, useMarkdown bool, sessionID string) (string, error) {
+func GenerateOutline(summary string, config *Config, useMarkdown bool, sessionID string, stream StreamFunc, stats *RunStats) (string, error) {
 	if summary == "" {
 		return "", fmt.Errorf("cannot generate outline from empty summary")
 	}
 
-	// Check cache first
-	cacheManager := NewCacheManager(config)
-	cacheKey := cacheManager.GetCacheKey(fmt.Sprintf("outline:%s:%t", summary[:Min(200, len(summary))], useMarkdown))
-	var cachedOutline string
-	if cacheManager.Get(cacheKey, &cachedOutline) {
-		DebugLog(config, "Cache hit for outline")
-		return cachedOutline, nil
-	}
-
 	systemPrompt := `You are an expert at creating clear, structured outlines. Create a hierarchical outline from the provided content.
 
 Rules:
@@ -417,12 +654,23 @@ Format as clean markdown:
 
 	userPrompt := fmt.Sprintf("Create a structured outline from this content:\n\n%s", summary)
 
+	// Check cache first
+	outlineStart := time.Now()
+	cacheManager := NewCacheManager(config)
+	cacheKey := cacheManager.PromptCacheKey("outline", TaskOutline, systemPrompt, userPrompt)
+	var cachedOutline string
+	if cacheManager.Get(cacheKey, &cachedOutline) {
+		DebugLog(config, "Cache hit for outline")
+		stats.Record("outline", outlineStart, true)
+		return cachedOutline, nil
+	}
+
 	var spinnerStop chan struct{}
-	if useMarkdown {
+	if useMarkdown && stream == nil {
 		spinnerStop = StartSpinner("Generating outline")
 	}
 
-	outline, err := callOllama(config, systemPrompt, userPrompt)
+	outline, err := callLLMStream(config, TaskOutline, systemPrompt, userPrompt, stream)
 	if spinnerStop != nil {
 		close(spinnerStop)
 	}
@@ -433,5 +681,6 @@ Format as clean markdown:
 
 	// Cache the result
 	cacheManager.Set(cacheKey, outline, sessionID)
+	stats.Record("outline", outlineStart, false)
 	return outline, nil
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newFakeLLM serves chat completions, answering length reductions and other
+// prompts differently, and counts the requests it receives
+func newFakeLLM(t *testing.T, calls *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		var req openAIChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		reply := "A detailed summary [1]."
+		if strings.Contains(req.Messages[0].Content, "content editor") {
+			reply = "Short [1]."
+		}
+		if !req.Stream {
+			data, _ := json.Marshal(map[string]interface{}{
+				"choices": []map[string]interface{}{{"message": openAIMessage{Role: "assistant", Content: reply}}},
+			})
+			w.Write(data)
+			return
+		}
+		data, _ := json.Marshal(map[string]interface{}{
+			"choices": []map[string]interface{}{{"delta": map[string]string{"content": reply}}},
+		})
+		fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSearchSummaryStreamsCachedReduction(t *testing.T) {
+	var calls int
+	server := newFakeLLM(t, &calls)
+
+	config := newTestConfig(t)
+	config.CacheEnabled = true
+	config.LLM.Provider = "openai"
+	config.LLM.BaseURL = server.URL
+
+	results := []SearchResult{{Title: "One", URL: "https://one.example", Snippet: "fact"}}
+	run := func() (string, string) {
+		var streamed strings.Builder
+		summary, err := generateSearchOnlySummaryTwoStage(config, "short", false, "q", results, "", func(token string) {
+			streamed.WriteString(token)
+		}, NewRunStats())
+		if err != nil {
+			t.Fatalf("summary: %v", err)
+		}
+		return summary, streamed.String()
+	}
+
+	first, firstStreamed := run()
+	if calls != 2 {
+		t.Fatalf("first run made %d model calls, want 2", calls)
+	}
+	if !strings.HasPrefix(firstStreamed, "Short [1].") || !strings.Contains(firstStreamed, "https://one.example") {
+		t.Errorf("first run streamed %q, want the reduction and its sources", firstStreamed)
+	}
+
+	// The reduction now comes from the cache and must still be streamed
+	second, secondStreamed := run()
+	if calls != 3 {
+		t.Fatalf("second run made %d model calls, want only the detailed summary", calls-2)
+	}
+	if second != first || secondStreamed != firstStreamed {
+		t.Errorf("cached run streamed %q, want %q", secondStreamed, firstStreamed)
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// WatchOptions controls a watch run
+type WatchOptions struct {
+	Interval    time.Duration
+	UseMarkdown bool
+	OutputFile  string // Append change reports here instead of printing them
+	Webhook     string // POST each change as JSON to this URL
+}
+
+// WatchedPage is the last seen version of a watched URL
+type WatchedPage struct {
+	URL         string    `json:"url"`
+	Title       string    `json:"title"`
+	Text        string    `json:"text"`
+	ContentHash string    `json:"content_hash"`
+	CheckedAt   time.Time `json:"checked_at"`
+	ChangedAt   time.Time `json:"changed_at"`
+}
+
+// WatchChange is a detected change, as delivered to the output and webhook
+type WatchChange struct {
+	URL        string    `json:"url"`
+	Title      string    `json:"title"`
+	DetectedAt time.Time `json:"detected_at"`
+	Summary    string    `json:"summary"`
+}
+
+// WatchState persists the watched pages in the config dir, so restarts
+// compare against what was seen before instead of taking a new baseline.
+// Changes stay queued in it until they have been delivered.
+type WatchState struct {
+	path        string
+	Pages       map[string]*WatchedPage `json:"pages"`
+	Undelivered []*WatchChange          `json:"undelivered,omitempty"`
+}
+
+// LoadWatchState reads the watch state, starting empty if there is none
+func LoadWatchState() (*WatchState, error) {
+	configDir, _ := os.UserConfigDir()
+	state := &WatchState{
+		path:  filepath.Join(configDir, appName, "watch.json"),
+		Pages: make(map[string]*WatchedPage),
+	}
+
+	data, err := os.ReadFile(state.path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid watch state %s: %v", state.path, err)
+	}
+	if state.Pages == nil {
+		state.Pages = make(map[string]*WatchedPage)
+	}
+	return state, nil
+}
+
+// Save writes the state atomically, so an interrupted save keeps the old one
+func (ws *WatchState) Save() error {
+	data, err := json.MarshalIndent(ws, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ws.path), 0755); err != nil {
+		return err
+	}
+
+	tmpPath := ws.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, ws.path)
+}
+
+// runWatchCommand handles "hvsum watch <url>..." and returns the exit code.
+// It checks every page right away and then once per interval until interrupted.
+func runWatchCommand(urls []string, config *Config, opts WatchOptions) int {
+	if len(urls) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s watch <url>... [--interval 1h] [--webhook URL] [-w file]\n", appName)
+		return 1
+	}
+	for i, input := range urls {
+		if !IsValidURL(input) {
+			fmt.Fprintf(os.Stderr, "Error: '%s' is not a URL\n", input)
+			return 1
+		}
+		urls[i] = normalizePageURL(input)
+	}
+	if opts.Interval <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: the interval must be positive\n")
+		return 1
+	}
+	if opts.Webhook != "" && config.Offline {
+		fmt.Fprintf(os.Stderr, "Error: cannot post to a webhook: %v\n", errOffline)
+		return 1
+	}
+
+	state, err := LoadWatchState()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "👀 Watching %d page(s) every %s (Ctrl+C to stop)\n", len(urls), opts.Interval)
+	for {
+		for _, pageURL := range urls {
+			if ctx.Err() != nil {
+				break
+			}
+
+			change, err := checkWatchedPage(pageURL, state, config, opts.UseMarkdown)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %s: %v\n", pageURL, err)
+				continue
+			}
+
+			// The change is queued before the state is saved, so one that
+			// can't be delivered now is retried after the next check
+			if change != nil {
+				state.Undelivered = append(state.Undelivered, change)
+			}
+			if err := state.Save(); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Could not save watch state: %v\n", err)
+			}
+			deliverPendingChanges(state, opts)
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Fprintf(os.Stderr, "\n👋 Stopped watching\n")
+			return 0
+		case <-time.After(opts.Interval):
+		}
+	}
+}
+
+// checkWatchedPage fetches a page and compares its extracted text with the
+// stored version. It returns nil without error when nothing changed. The
+// state is only advanced once the change has been summarized, so a failed
+// summary is retried on the next check.
+func checkWatchedPage(pageURL string, state *WatchState, config *Config, useMarkdown bool) (*WatchChange, error) {
+	content, title, err := ExtractWebContent(pageURL, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compare normalized text so reflowed whitespace doesn't count as a change
+	hash := contentHash(strings.Join(strings.Fields(content), " "))
+	now := time.Now()
+
+	page, seen := state.Pages[pageURL]
+	if !seen {
+		state.Pages[pageURL] = &WatchedPage{
+			URL:         pageURL,
+			Title:       title,
+			Text:        content,
+			ContentHash: hash,
+			CheckedAt:   now,
+			ChangedAt:   now,
+		}
+		fmt.Fprintf(os.Stderr, "📌 %s: recorded the current version\n", pageURL)
+		return nil, nil
+	}
+
+	page.CheckedAt = now
+	if page.ContentHash == hash {
+		DebugLog(config, "No change: %s", pageURL)
+		return nil, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "🔔 %s changed, summarizing...\n", pageURL)
+	summary, err := SummarizeChanges(config, title, page.Text, content, useMarkdown)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize changes: %v", err)
+	}
+
+	page.Title = title
+	page.Text = content
+	page.ContentHash = hash
+	page.ChangedAt = now
+
+	return &WatchChange{
+		URL:        pageURL,
+		Title:      title,
+		DetectedAt: now,
+		Summary:    summary,
+	}, nil
+}
+
+// SummarizeChanges asks the model what changed between two extractions of
+// the same document. The model sees the changed lines with a little context
+// rather than both versions, so changes anywhere in a long page fit.
+func SummarizeChanges(config *Config, title, oldText, newText string, useMarkdown bool) (string, error) {
+	systemPrompt := `You compare two versions of the same document and report what changed, working from a line diff.
+
+In the diff, lines starting with "-" were removed, lines starting with "+" were added and lines starting with a space are unchanged context. "@@" separates distant parts of the document. A removed line followed by a similar added line is a modification.
+
+Rules:
+1. Describe additions, removals and modifications, most important first
+2. Quote exact values (versions, dates, numbers, statuses) where they changed
+3. Only use the context lines to explain where a change is
+4. Do not summarize the document itself
+5. If the versions only differ in trivial ways, say so in one sentence`
+
+	if useMarkdown {
+		systemPrompt += "\n\nFormat the changes as a markdown bullet list."
+	}
+
+	diff := FormatDiff(DiffLines(splitDiffLines(oldText), splitDiffLines(newText)), diffContextLines)
+	if diff == "" {
+		return "Only whitespace changed.", nil
+	}
+
+	model, _ := config.ModelFor(TaskSummary)
+	diffTokens := Max(config.ContextBudget(model)-1024, 1024)
+
+	userPrompt := fmt.Sprintf(`Document: %s
+
+Diff from the previous to the current version:
+%s
+
+What changed between the previous and the current version?`, title, strings.TrimRight(truncateTokens(diff, diffTokens), "\n"))
+
+	cacheManager := NewCacheManager(config)
+	cacheKey := cacheManager.PromptCacheKey("changes", TaskSummary, systemPrompt, userPrompt)
+	var cachedSummary string
+	if cacheManager.Get(cacheKey, &cachedSummary) {
+		DebugLog(config, "Cache hit for change summary")
+		return cachedSummary, nil
+	}
+
+	summary, err := callLLM(config, TaskSummary, systemPrompt, userPrompt)
+	if err != nil {
+		return "", err
+	}
+
+	cacheManager.Set(cacheKey, summary, "")
+	return summary, nil
+}
+
+// truncateTokens cuts text to roughly maxTokens without splitting a character
+func truncateTokens(text string, maxTokens int) string {
+	runes := []rune(text)
+	if len(runes) <= maxTokens*charsPerToken {
+		return text
+	}
+	return string(runes[:maxTokens*charsPerToken]) + "\n[...truncated]"
+}
+
+// deliverPendingChanges delivers the queued changes oldest first. It stops
+// at the first failure and keeps the rest queued, so changes arrive in order.
+func deliverPendingChanges(state *WatchState, opts WatchOptions) {
+	delivered := 0
+	for _, change := range state.Undelivered {
+		if err := deliverWatchChange(change, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Could not deliver change for %s, retrying later: %v\n", change.URL, err)
+			break
+		}
+		delivered++
+	}
+	if delivered == 0 {
+		return
+	}
+
+	state.Undelivered = state.Undelivered[delivered:]
+	if err := state.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not save watch state: %v\n", err)
+	}
+}
+
+// deliverWatchChange posts the change to the webhook, then prints it or
+// appends it to the output file. The webhook goes first as it is the most
+// likely to fail, and a retried change shouldn't be written twice.
+func deliverWatchChange(change *WatchChange, opts WatchOptions) error {
+	if opts.Webhook != "" {
+		if err := postWebhook(opts.Webhook, change); err != nil {
+			return err
+		}
+	}
+
+	report := fmt.Sprintf("## Changes: %s\n\n%s (detected %s)\n\n%s\n\n",
+		change.Title, change.URL, change.DetectedAt.Format("2006-01-02 15:04"), change.Summary)
+
+	if opts.OutputFile != "" {
+		file, err := os.OpenFile(opts.OutputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = file.WriteString(report)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "💾 Change for %s appended to %s\n", change.URL, opts.OutputFile)
+	} else {
+		RenderToConsole(report, opts.UseMarkdown)
+	}
+	return nil
+}
+
+// postWebhook sends a change as a JSON POST request
+func postWebhook(webhookURL string, change *WatchChange) error {
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("webhook failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"path"
 	"strings"
 	"time"
 
@@ -11,16 +15,58 @@ import (
 	"github.com/microcosm-cc/bluemonday"
 )
 
-// ExtractWebContent fetches and extracts clean content from a URL
-func ExtractWebContent(urlStr string) (string, string, error) {
-	// Add https:// if no protocol is specified
+// maxContentBytes bounds how much of a page or file is read into memory
+const maxContentBytes = 50 * 1024 * 1024
+
+// normalizePageURL adds https:// if no protocol is specified
+func normalizePageURL(urlStr string) string {
 	if !strings.HasPrefix(urlStr, "http://") && !strings.HasPrefix(urlStr, "https://") {
-		urlStr = "https://" + urlStr
+		return "https://" + urlStr
 	}
+	return urlStr
+}
+
+// ExtractWebContent fetches and extracts clean content from a URL. Fetched
+// pages are kept in the page cache with their ETag and Last-Modified
+// headers, so refetches are conditional and a 304 reuses the stored
+// extraction. If the refetch fails with a network error or a 5xx status,
+// the cached copy is used. Offline mode only reads the page cache.
+func ExtractWebContent(urlStr string, config *Config) (string, string, error) {
+	content, title, _, err := fetchWebContent(urlStr, config)
+	return content, title, err
+}
+
+// fetchWebContent is ExtractWebContent that also reports whether the
+// content came from the page cache
+func fetchWebContent(urlStr string, config *Config) (string, string, bool, error) {
+	urlStr = normalizePageURL(urlStr)
 
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to parse URL: %w", err)
+		return "", "", false, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	pages := NewPageCache(config)
+	cached, hasCached := pages.Get(urlStr)
+	if config.Offline {
+		if !hasCached {
+			return "", "", false, fmt.Errorf("%s has not been fetched before: %w", urlStr, errOffline)
+		}
+		DebugLog(config, "Offline: using page fetched %s", cached.FetchedAt.Format(time.RFC3339))
+		return cached.Text, cached.Title, true, nil
+	}
+
+	req, err := http.NewRequest("GET", urlStr, nil)
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to create request: %v", err)
+	}
+	if hasCached {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
 	}
 
 	// Create HTTP client with timeout
@@ -28,24 +74,104 @@ func ExtractWebContent(urlStr string) (string, string, error) {
 		Timeout: 30 * time.Second,
 	}
 
-	resp, err := client.Get(urlStr)
+	release := acquireFetchSlot()
+	defer release()
+
+	// A cached copy keeps the summaries derived from it usable while the
+	// site is unreachable or failing
+	resp, err := client.Do(req)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to fetch URL: %v", err)
+		if hasCached {
+			DebugLog(config, "Fetching %s failed (%v), using the copy fetched %s", urlStr, err, cached.FetchedAt.Format(time.RFC3339))
+			return cached.Text, cached.Title, true, nil
+		}
+		return "", "", false, fmt.Errorf("failed to fetch URL: %v", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && hasCached {
+		DebugLog(config, "Not modified since %s: %s", cached.FetchedAt.Format(time.RFC3339), urlStr)
+		return cached.Text, cached.Title, true, nil
+	}
+
+	if resp.StatusCode >= 500 && hasCached {
+		DebugLog(config, "Fetching %s failed (%s), using the copy fetched %s", urlStr, resp.Status, cached.FetchedAt.Format(time.RFC3339))
+		return cached.Text, cached.Title, true, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return "", "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		return "", "", false, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
 	}
 
-	article, err := readability.FromReader(resp.Body, parsedURL)
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxContentBytes))
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	content, title, err := ExtractContent(data, contentType, parsedURL, "Web Page Summary")
+	if err != nil {
+		return "", "", false, err
+	}
+
+	page := &CachedPage{
+		URL:          urlStr,
+		ContentType:  contentType,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Body:         data,
+		Title:        title,
+		Text:         content,
+		FetchedAt:    time.Now(),
+	}
+	if !hasCached {
+		cached = nil
+	}
+	if err := pages.Update(cached, page); err != nil {
+		DebugLog(config, "Failed to cache page %s: %v", urlStr, err)
+	}
+	return content, title, false, nil
+}
+
+// ExtractContent picks an extractor based on the MIME type of the data,
+// sniffing the type when none is given
+func ExtractContent(data []byte, mimeType string, pageURL *url.URL, fallbackTitle string) (string, string, error) {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil || mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+
+	switch {
+	case mediaType == "application/pdf":
+		if pageURL != nil && strings.HasSuffix(strings.ToLower(pageURL.Path), ".pdf") {
+			fallbackTitle = path.Base(pageURL.Path)
+		}
+		return extractPDF(data, fallbackTitle)
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return extractHTML(data, pageURL, fallbackTitle)
+	case mediaType == "text/markdown" || mediaType == "text/x-markdown":
+		return extractMarkdown(data, fallbackTitle)
+	case strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" || mediaType == "application/xml":
+		return extractPlainText(data, fallbackTitle)
+	default:
+		return "", "", fmt.Errorf("unsupported content type: %s", mediaType)
+	}
+}
+
+// extractHTML runs readability over an HTML document
+func extractHTML(data []byte, pageURL *url.URL, fallbackTitle string) (string, string, error) {
+	if pageURL == nil {
+		pageURL = &url.URL{}
+	}
+
+	article, err := readability.FromReader(bytes.NewReader(data), pageURL)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to parse content: %v", err)
 	}
 
 	pageTitle := article.Title
 	if pageTitle == "" {
-		pageTitle = "Web Page Summary"
+		pageTitle = fallbackTitle
 	}
 
 	textContent := article.TextContent
@@ -63,3 +189,31 @@ func ExtractWebContent(urlStr string) (string, string, error) {
 
 	return textContent, pageTitle, nil
 }
+
+// extractMarkdown keeps markdown as-is and uses the first heading as title
+func extractMarkdown(data []byte, fallbackTitle string) (string, string, error) {
+	content := strings.TrimSpace(string(data))
+	if content == "" {
+		return "", fallbackTitle, fmt.Errorf("document is empty")
+	}
+
+	title := fallbackTitle
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "# ") {
+			title = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+			break
+		}
+	}
+
+	return content, title, nil
+}
+
+// extractPlainText returns text content unchanged
+func extractPlainText(data []byte, fallbackTitle string) (string, string, error) {
+	content := strings.TrimSpace(string(data))
+	if content == "" {
+		return "", fallbackTitle, fmt.Errorf("document is empty")
+	}
+	return content, fallbackTitle, nil
+}